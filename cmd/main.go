@@ -2,9 +2,15 @@ package main
 
 import (
 	"OzonTestTask/internal/config"
+	"OzonTestTask/internal/graphql/apierrors"
+	"OzonTestTask/internal/graphql/dataloader"
 	"OzonTestTask/internal/graphql/generated"
+	"OzonTestTask/internal/graphql/i18n"
+	"OzonTestTask/internal/graphql/limits"
+	"OzonTestTask/internal/graphql/persisted"
 	"OzonTestTask/internal/graphql/resolvers"
 	"OzonTestTask/internal/service/comment"
+	"OzonTestTask/internal/service/outbox"
 	"OzonTestTask/internal/service/post"
 	in_memory "OzonTestTask/internal/storage/in-memory"
 	"OzonTestTask/internal/storage/postgreSQL"
@@ -12,9 +18,12 @@ import (
 	"context"
 	"fmt"
 	"github.com/99designs/gqlgen/graphql/handler"
+	"github.com/99designs/gqlgen/graphql/handler/extension"
+	"github.com/99designs/gqlgen/graphql/handler/lru"
 	"github.com/99designs/gqlgen/graphql/handler/transport"
 	"github.com/99designs/gqlgen/graphql/playground"
 	"github.com/joho/godotenv"
+	"github.com/vektah/gqlparser/v2/ast"
 	"log"
 	"net/http"
 	"os"
@@ -33,6 +42,15 @@ func main() {
 	var commentService *comment.CommentService
 	var subService subscription.Subscription
 
+	overflow, err := subscription.ParseOverflowPolicy(conf.SubscriptionOverflow)
+	if err != nil {
+		log.Fatalf("некорректная настройка подписок: %v", err)
+	}
+	subOptions := subscription.Options{BufferSize: conf.SubscriptionBufferSize, Overflow: overflow}
+
+	relayCtx, stopRelay := context.WithCancel(context.Background())
+	defer stopRelay()
+
 	if conf.StorageType == config.PostgresStorage {
 		// подключение к БД
 		db, err := postgreSQL.NewDBConnection(conf.PostgresDSN)
@@ -50,12 +68,17 @@ func main() {
 		defer pool.Close()
 
 		storage := postgreSQL.NewStorage(db)
-		subService = subscription.NewPostgresSubscription(pool)
+		// комментарии, не поместившиеся в NOTIFY, подписчики догружают из снимков в outbox
+		subService = subscription.NewPostgresSubscription(pool, storage, subOptions)
 		postService = post.NewPostService(storage)
-		commentService = comment.NewCommentService(storage, subService)
+		// уведомление о новом комментарии пишется в outbox в транзакции создания комментария,
+		// подписчикам его отправляет relay, поэтому сервис сам не публикует
+		commentService = comment.NewCommentService(storage, nil)
+		relay := outbox.NewRelay(storage, subService, outbox.DefaultPollInterval)
+		go relay.Run(relayCtx)
 
 	} else if conf.StorageType == config.InMemoryStorage {
-		subService = subscription.NewInMemorySubscription()
+		subService = subscription.NewInMemorySubscription(subOptions)
 		inMemoryStorage := in_memory.NewInMemoryStorage()
 		postService = post.NewPostService(inMemoryStorage)
 		commentService = comment.NewCommentService(inMemoryStorage, subService)
@@ -71,14 +94,40 @@ func main() {
 	}
 
 	server := handler.New(generated.NewExecutableSchema(generated.Config{
-		Resolvers: resolver,
+		Resolvers:  resolver,
+		Complexity: limits.Complexity(limits.DefaultCosts),
 	}))
+	// ошибки сервисов и хранилищ получают код в extensions.code
+	server.SetErrorPresenter(apierrors.Presenter)
+	// подписка, закрытая сервером (например, из-за медленного клиента), завершается событием с ошибкой
+	server.Use(apierrors.StreamErrors{})
 	server.AddTransport(transport.POST{})
 	server.AddTransport(transport.GET{})
-	server.AddTransport(transport.Websocket{})
+	// язык сообщений об ошибках в подписках можно передать в payload connection_init
+	server.AddTransport(transport.Websocket{InitFunc: i18n.WebsocketInit})
+	// разобранные и провалидированные документы кэширую, одинаковые запросы не парсятся заново
+	server.SetQueryCache(lru.New[*ast.QueryDocument](conf.APQCacheSize))
+	// слишком сложные и слишком глубокие запросы отклоняются до вызова резолверов
+	server.Use(extension.FixedComplexityLimit(conf.MaxQueryComplexity))
+	server.Use(limits.DepthLimit{MaxDepth: conf.MaxQueryDepth})
+
+	// в строгом режиме выполняются только запросы из манифеста, иначе клиенты могут присылать
+	// вместо текста запроса его хэш (APQ), а тексты хранятся в LRU-кэше
+	if conf.QueryManifestPath != "" {
+		manifest, err := persisted.LoadManifest(conf.QueryManifestPath)
+		if err != nil {
+			log.Fatalf("не удалось загрузить список разрешенных запросов: %v", err)
+		}
+		server.Use(persisted.Allowlist{Manifest: manifest})
+		fmt.Printf("Строгий режим: разрешено запросов - %d\n", manifest.Len())
+	} else {
+		server.Use(extension.AutomaticPersistedQuery{Cache: lru.New[string](conf.APQCacheSize)})
+	}
 
 	http.Handle("/", playground.Handler("GraphQL Playground", "/graphql"))
-	http.Handle("/graphql", server)
+	// загрузчики создаются на каждый запрос, чтобы поля списков (например, comments у постов) грузились пачкой,
+	// язык сообщений об ошибках выбирается по заголовку Accept-Language
+	http.Handle("/graphql", i18n.Middleware(dataloader.Middleware(postService, commentService, server)))
 
 	port := ":8080"
 
@@ -102,5 +151,6 @@ func main() {
 	ctx, cancel := context.WithTimeout(context.Background(), 3*time.Second)
 	defer cancel()
 	httpServer.Shutdown(ctx)
+	stopRelay()
 	subService.Close()
 }
@@ -4,6 +4,7 @@ import (
 	"fmt"
 	"log"
 	"os"
+	"strconv"
 )
 
 type StorageType string
@@ -13,17 +14,49 @@ const (
 	InMemoryStorage StorageType = "memory"
 )
 
+// значения по умолчанию для ограничений запросов, если переменные окружения не заданы
+const (
+	defaultMaxQueryComplexity = 1000
+	defaultMaxQueryDepth      = 10
+	defaultAPQCacheSize       = 1000
+
+	defaultSubscriptionBufferSize = 16
+	defaultSubscriptionOverflow   = "drop_oldest"
+)
+
 type Config struct {
 	Port        string
 	StorageType StorageType
 	PostgresDSN string
+
+	MaxQueryComplexity int
+	MaxQueryDepth      int
+
+	APQCacheSize int
+	// QueryManifestPath путь к манифесту разрешенных запросов; если задан - сервер работает в строгом режиме
+	QueryManifestPath string
+
+	// SubscriptionBufferSize сколько непрочитанных событий хранится на подписчика,
+	// SubscriptionOverflow что делать при переполнении: drop_oldest, drop_newest или disconnect
+	SubscriptionBufferSize int
+	SubscriptionOverflow   string
 }
 
 func NewConfig() *Config {
 	storageType := getEnv("STORAGE_TYPE")
 	conf := &Config{
-		Port:        getEnv("PORT"),
-		StorageType: StorageType(storageType),
+		Port:               getEnv("PORT"),
+		StorageType:        StorageType(storageType),
+		MaxQueryComplexity: getEnvInt("MAX_QUERY_COMPLEXITY", defaultMaxQueryComplexity),
+		MaxQueryDepth:      getEnvInt("MAX_QUERY_DEPTH", defaultMaxQueryDepth),
+		APQCacheSize:       getEnvInt("APQ_CACHE_SIZE", defaultAPQCacheSize),
+		QueryManifestPath:  os.Getenv("QUERY_MANIFEST_PATH"),
+
+		SubscriptionBufferSize: getEnvInt("SUBSCRIPTION_BUFFER_SIZE", defaultSubscriptionBufferSize),
+		SubscriptionOverflow:   os.Getenv("SUBSCRIPTION_OVERFLOW_POLICY"),
+	}
+	if conf.SubscriptionOverflow == "" {
+		conf.SubscriptionOverflow = defaultSubscriptionOverflow
 	}
 
 	if conf.StorageType == PostgresStorage {
@@ -41,6 +74,19 @@ func getEnv(key string) string {
 	return env
 }
 
+// getEnvInt необязательная числовая переменная окружения
+func getEnvInt(key string, defaultValue int) int {
+	env := os.Getenv(key)
+	if env == "" {
+		return defaultValue
+	}
+	value, err := strconv.Atoi(env)
+	if err != nil || value <= 0 {
+		log.Fatalf("некорректное значение переменной окружения %s: %s", key, env)
+	}
+	return value
+}
+
 func getDSN() string {
 	db := getEnv("POSTGRES_DB")
 	user := getEnv("POSTGRES_USER")
@@ -0,0 +1,115 @@
+package apierrors
+
+import (
+	"OzonTestTask/internal/graphql/i18n"
+	"OzonTestTask/internal/pagination"
+	"OzonTestTask/internal/service"
+	"fmt"
+)
+
+// messages Каталог сообщений: по ключу ошибки (service.MessageKeyer), а если его нет - по коду ошибки
+var messages = map[string]map[i18n.Language]string{
+	CodeNotFound: {
+		i18n.Russian: "объект не найден",
+		i18n.English: "object not found",
+	},
+	CodeCommentsDisabled: {
+		i18n.Russian: "этот пост запрещено комментировать",
+		i18n.English: "comments are disabled for this post",
+	},
+	CodeValidation: {
+		i18n.Russian: "некорректные входные данные",
+		i18n.English: "invalid input",
+	},
+	CodeConflict: {
+		i18n.Russian: "операция противоречит текущему состоянию объекта",
+		i18n.English: "operation conflicts with the current state of the object",
+	},
+	CodeSubscriberSlow: {
+		i18n.Russian: "подписчик не успевает получать события, подписка закрыта",
+		i18n.English: "subscriber is too slow to receive events, subscription closed",
+	},
+	CodeInternal: {
+		i18n.Russian: "внутренняя ошибка сервера",
+		i18n.English: "internal server error",
+	},
+
+	"POST_NOT_FOUND": {
+		i18n.Russian: "пост не найден",
+		i18n.English: "post not found",
+	},
+	"COMMENT_NOT_FOUND": {
+		i18n.Russian: "комментарий не найден",
+		i18n.English: "comment not found",
+	},
+	"PARENT_COMMENT_NOT_FOUND": {
+		i18n.Russian: "комментарий для ответа не найден",
+		i18n.English: "comment to reply to not found",
+	},
+	"COMMENT_DELETED": {
+		i18n.Russian: "удаленный комментарий нельзя редактировать",
+		i18n.English: "deleted comment cannot be edited",
+	},
+
+	service.KeyTitleRequired: {
+		i18n.Russian: "заголовок поста не может быть пустым",
+		i18n.English: "post title must not be empty",
+	},
+	service.KeyPostContentRequired: {
+		i18n.Russian: "пост не может быть пустым",
+		i18n.English: "post content must not be empty",
+	},
+	service.KeyAuthorRequired: {
+		i18n.Russian: "имя автора не может быть пустым",
+		i18n.English: "author name must not be empty",
+	},
+	service.KeyCommentRequired: {
+		i18n.Russian: "комментарий не может быть пустым",
+		i18n.English: "comment must not be empty",
+	},
+	service.KeyCommentTooLong: {
+		i18n.Russian: "длина комментария не должна превышать 2000 символов",
+		i18n.English: "comment must not exceed 2000 characters",
+	},
+	service.KeyInvalidID: {
+		i18n.Russian: "некорректный id",
+		i18n.English: "invalid id",
+	},
+	service.KeyInvalidCursor: {
+		i18n.Russian: "некорректный курсор",
+		i18n.English: "invalid cursor",
+	},
+	service.KeyFirstAndLast: {
+		i18n.Russian: "нельзя одновременно указывать first и last",
+		i18n.English: "first and last must not be used together",
+	},
+	service.KeyInvalidPageSize: {
+		i18n.Russian: fmt.Sprintf("размер страницы должен быть от 1 до %d", pagination.MaxLimit),
+		i18n.English: fmt.Sprintf("page size must be between 1 and %d", pagination.MaxLimit),
+	},
+	service.KeyInvalidMaxDepth: {
+		i18n.Russian: "глубина вложенности должна быть не меньше 1",
+		i18n.English: "maxDepth must be at least 1",
+	},
+	service.KeyTooManyIDs: {
+		i18n.Russian: fmt.Sprintf("можно запросить не больше %d объектов", pagination.MaxLimit),
+		i18n.English: fmt.Sprintf("at most %d objects can be requested", pagination.MaxLimit),
+	},
+	service.KeyInvalidDateTime: {
+		i18n.Russian: "время должно быть строкой в формате RFC3339 с часовым поясом, например 2024-01-02T15:04:05Z",
+		i18n.English: "time must be an RFC3339 string with a time zone, e.g. 2024-01-02T15:04:05Z",
+	},
+}
+
+// Message Сообщение для ключа на языке lang. ok = false, если ключа нет в каталоге
+func Message(key string, lang i18n.Language) (message string, ok bool) {
+	translations, ok := messages[key]
+	if !ok {
+		return "", false
+	}
+	if message, ok = translations[lang]; ok {
+		return message, true
+	}
+	message, ok = translations[i18n.Default]
+	return message, ok
+}
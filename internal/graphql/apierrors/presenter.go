@@ -0,0 +1,97 @@
+package apierrors
+
+import (
+	"OzonTestTask/internal/graphql/i18n"
+	"OzonTestTask/internal/service"
+	"OzonTestTask/internal/subscription"
+	"context"
+	"errors"
+	"log"
+
+	"github.com/99designs/gqlgen/graphql"
+	"github.com/99designs/gqlgen/graphql/errcode"
+	"github.com/vektah/gqlparser/v2/gqlerror"
+)
+
+// Коды ошибок в extensions.code. Клиенты различают ошибки по коду, а не по тексту
+const (
+	CodeNotFound         = "NOT_FOUND"
+	CodeCommentsDisabled = "COMMENTS_DISABLED"
+	CodeValidation       = "VALIDATION_FAILED"
+	CodeConflict         = "CONFLICT"
+	CodeSubscriberSlow   = "SUBSCRIBER_TOO_SLOW"
+	CodeInternal         = "INTERNAL"
+)
+
+// Code Код для ошибки сервиса или хранилища. ok = false, если вид ошибки неизвестен
+func Code(err error) (code string, ok bool) {
+	switch {
+	case errors.Is(err, service.ErrNotFound):
+		return CodeNotFound, true
+	case errors.Is(err, service.ErrCommentsDisabled):
+		return CodeCommentsDisabled, true
+	case errors.Is(err, service.ErrValidation):
+		return CodeValidation, true
+	case errors.Is(err, service.ErrConflict):
+		return CodeConflict, true
+	case errors.Is(err, subscription.ErrSubscriberTooSlow):
+		return CodeSubscriberSlow, true
+	}
+	return "", false
+}
+
+// Presenter Презентер ошибок для gqlgen: добавляет в extensions код ошибки,
+// а для ошибок валидации - имя аргумента, к которому она относится:
+//
+//	"extensions": {"code": "VALIDATION_FAILED", "field": "content"}
+//
+// Текст ошибки с кодом заменяется сообщением из каталога на языке запроса (i18n.FromContext).
+// Неизвестные ошибки (например, недоступность хранилища) получают код INTERNAL и общее сообщение,
+// чтобы текст ошибки БД не попадал клиенту, а исходная ошибка пишется в лог.
+// Ошибки самого gqlgen (разбор, валидация и сложность запроса) уже написаны для клиента и отдаются как есть.
+// Extensions, добавленные резолвером (например, id в nodes), сохраняются
+func Presenter(ctx context.Context, err error) *gqlerror.Error {
+	gqlErr := graphql.DefaultErrorPresenter(ctx, err)
+
+	code, ok := Code(err)
+	if !ok {
+		if fromGqlgen(err) {
+			return gqlErr
+		}
+		log.Printf("внутренняя ошибка %s: %v", gqlErr.Path, err)
+		code = CodeInternal
+	}
+	errcode.Set(gqlErr, code)
+	gqlErr.Message = localize(err, code, i18n.FromContext(ctx))
+
+	var validationErr *service.ValidationError
+	if errors.As(err, &validationErr) && validationErr.Field != "" {
+		gqlErr.Extensions["field"] = validationErr.Field
+	}
+	return gqlErr
+}
+
+// fromGqlgen Ошибка создана gqlgen или расширением сервера: в цепочке только *gqlerror.Error.
+// Ошибки резолверов gqlgen тоже оборачивает в *gqlerror.Error, но их причина - обычная ошибка
+func fromGqlgen(err error) bool {
+	for err != nil {
+		gqlErr, ok := err.(*gqlerror.Error)
+		if !ok {
+			return false
+		}
+		err = gqlErr.Err
+	}
+	return true
+}
+
+// localize Сообщение по ключу ошибки, если его нет в каталоге - по коду
+func localize(err error, code string, lang i18n.Language) string {
+	var keyed service.MessageKeyer
+	if errors.As(err, &keyed) {
+		if message, ok := Message(keyed.MessageKey(), lang); ok {
+			return message
+		}
+	}
+	message, _ := Message(code, lang)
+	return message
+}
@@ -0,0 +1,222 @@
+package apierrors
+
+import (
+	"OzonTestTask/internal/graphql/generated"
+	"OzonTestTask/internal/graphql/i18n"
+	"OzonTestTask/internal/graphql/relay"
+	"OzonTestTask/internal/graphql/resolvers"
+	"OzonTestTask/internal/model"
+	"OzonTestTask/internal/service"
+	"OzonTestTask/internal/service/comment"
+	"OzonTestTask/internal/service/post"
+	"OzonTestTask/internal/storage"
+	in_memory "OzonTestTask/internal/storage/in-memory"
+	"OzonTestTask/internal/subscription"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"github.com/99designs/gqlgen/graphql"
+	"github.com/99designs/gqlgen/graphql/handler"
+	"github.com/99designs/gqlgen/graphql/handler/transport"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"github.com/vektah/gqlparser/v2/ast"
+	"github.com/vektah/gqlparser/v2/gqlerror"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+)
+
+type response struct {
+	Data   json.RawMessage `json:"data"`
+	Errors []struct {
+		Message    string         `json:"message"`
+		Extensions map[string]any `json:"extensions"`
+	} `json:"errors"`
+}
+
+func newServer() (http.Handler, *in_memory.InMemoryStorage) {
+	store := in_memory.NewInMemoryStorage()
+	server := handler.New(generated.NewExecutableSchema(generated.Config{
+		Resolvers: &resolvers.Resolver{
+			PostService:    post.NewPostService(store),
+			CommentService: comment.NewCommentService(store, nil),
+		},
+	}))
+	server.SetErrorPresenter(Presenter)
+	server.AddTransport(transport.POST{})
+	return server, store
+}
+
+func doQuery(t *testing.T, server http.Handler, query string) response {
+	return doQueryLang(t, server, query, "")
+}
+
+func doQueryLang(t *testing.T, server http.Handler, query, acceptLanguage string) response {
+	body, err := json.Marshal(map[string]string{"query": query})
+	require.NoError(t, err)
+
+	req := httptest.NewRequest(http.MethodPost, "/graphql", strings.NewReader(string(body)))
+	req.Header.Set("Content-Type", "application/json")
+	if acceptLanguage != "" {
+		req.Header.Set("Accept-Language", acceptLanguage)
+	}
+	rec := httptest.NewRecorder()
+	i18n.Middleware(server).ServeHTTP(rec, req)
+
+	var resp response
+	require.NoError(t, json.Unmarshal(rec.Body.Bytes(), &resp))
+	return resp
+}
+
+func TestPresenter(t *testing.T) {
+	server, store := newServer()
+	ctx := context.Background()
+
+	closed := &model.Post{Title: "Закрытый", Content: "Текст", Author: "Даша"}
+	require.NoError(t, store.CreatePost(ctx, closed))
+	open := &model.Post{Title: "Открытый", Content: "Текст", Author: "Даша", AreCommentsAllowed: true}
+	require.NoError(t, store.CreatePost(ctx, open))
+	deleted := &model.Comment{PostID: open.ID, Author: "Анна", Content: "Удаленный"}
+	require.NoError(t, store.CreateComment(ctx, deleted))
+	_, err := store.DeleteComment(ctx, deleted.ID)
+	require.NoError(t, err)
+
+	tests := []struct {
+		name  string
+		query string
+		code  string
+		field string
+	}{
+		{"не найден", `{ post(id: "100") { id } }`, CodeNotFound, ""},
+		{"комментарии запрещены", fmt.Sprintf(`mutation { createComment(postId: "%d", author: "Анна", content: "Текст") { id } }`, closed.ID), CodeCommentsDisabled, ""},
+		{"валидация", fmt.Sprintf(`mutation { createComment(postId: "%d", author: "Анна", content: "") { id } }`, open.ID), CodeValidation, "content"},
+		{"некорректный id", `{ post(id: "abc") { id } }`, CodeValidation, "id"},
+		{"конфликт", fmt.Sprintf(`mutation { editComment(id: "%d", content: "Новый") { id } }`, deleted.ID), CodeConflict, ""},
+		{"некорректное время", `{ posts(createdAfter: "2024-01-02") { id } }`, CodeValidation, ""},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			resp := doQuery(t, server, tt.query)
+			require.Len(t, resp.Errors, 1)
+			assert.Equal(t, tt.code, resp.Errors[0].Extensions["code"])
+			if tt.field != "" {
+				assert.Equal(t, tt.field, resp.Errors[0].Extensions["field"])
+			} else {
+				assert.NotContains(t, resp.Errors[0].Extensions, "field")
+			}
+		})
+	}
+}
+
+func TestPresenter_UnknownError(t *testing.T) {
+	err := fmt.Errorf("не удалось получить пост: %w", errors.New("pq: connection refused"))
+	_, ok := Code(err)
+	assert.False(t, ok)
+
+	// текст внутренней ошибки клиенту не отдается
+	gqlErr := Presenter(i18n.NewContext(context.Background(), i18n.English), err)
+	assert.Equal(t, CodeInternal, gqlErr.Extensions["code"])
+	assert.Equal(t, "internal server error", gqlErr.Message)
+
+	// gqlgen оборачивает ошибки резолверов в gqlerror - текст внутренней ошибки все равно скрывается
+	gqlErr = Presenter(context.Background(), gqlerror.WrapPath(ast.Path{ast.PathName("post")}, err))
+	assert.Equal(t, CodeInternal, gqlErr.Extensions["code"])
+	assert.Equal(t, "внутренняя ошибка сервера", gqlErr.Message)
+	gqlErr = Presenter(context.Background(), gqlerror.Wrap(err))
+	assert.Equal(t, CodeInternal, gqlErr.Extensions["code"])
+
+	// ошибки gqlgen описывают некорректный запрос и остаются как есть
+	gqlErr = Presenter(context.Background(), gqlerror.Errorf("cannot query field"))
+	assert.Equal(t, "cannot query field", gqlErr.Message)
+	assert.NotContains(t, gqlErr.Extensions, "code")
+}
+
+func TestPresenter_NodesKeepID(t *testing.T) {
+	server, _ := newServer()
+	missing := relay.ToGlobalID(relay.TypePost, 100)
+
+	// сообщение переводится, но по id в extensions видно, какой из объектов не найден
+	resp := doQueryLang(t, server, fmt.Sprintf(`{ nodes(ids: [%q]) { id } }`, missing), "en")
+	require.Len(t, resp.Errors, 1)
+	assert.Equal(t, "post not found", resp.Errors[0].Message)
+	assert.Equal(t, CodeNotFound, resp.Errors[0].Extensions["code"])
+	assert.Equal(t, missing, resp.Errors[0].Extensions["id"])
+	assert.JSONEq(t, `{"nodes":[null]}`, string(resp.Data))
+}
+
+func TestPresenter_Localized(t *testing.T) {
+	server, store := newServer()
+	open := &model.Post{Title: "Открытый", Content: "Текст", Author: "Даша", AreCommentsAllowed: true}
+	require.NoError(t, store.CreatePost(context.Background(), open))
+	query := fmt.Sprintf(`mutation { createComment(postId: "%d", author: "Анна", content: "") { id } }`, open.ID)
+
+	resp := doQueryLang(t, server, query, "en-US,en;q=0.9")
+	require.Len(t, resp.Errors, 1)
+	assert.Equal(t, "comment must not be empty", resp.Errors[0].Message)
+	assert.Equal(t, CodeValidation, resp.Errors[0].Extensions["code"])
+
+	resp = doQueryLang(t, server, query, "ru")
+	require.Len(t, resp.Errors, 1)
+	assert.Equal(t, "комментарий не может быть пустым", resp.Errors[0].Message)
+
+	resp = doQueryLang(t, server, `{ post(id: "100") { id } }`, "en")
+	require.Len(t, resp.Errors, 1)
+	assert.Equal(t, "post not found", resp.Errors[0].Message)
+}
+
+// у каждого ключа и кода ошибки должен быть перевод на все поддерживаемые языки
+func TestMessages_Complete(t *testing.T) {
+	keys := []string{CodeNotFound, CodeCommentsDisabled, CodeValidation, CodeConflict, CodeSubscriberSlow, CodeInternal,
+		service.KeyTitleRequired, service.KeyPostContentRequired, service.KeyAuthorRequired,
+		service.KeyCommentRequired, service.KeyCommentTooLong, service.KeyInvalidID,
+		service.KeyInvalidCursor, service.KeyFirstAndLast, service.KeyInvalidPageSize, service.KeyInvalidMaxDepth,
+		service.KeyTooManyIDs, service.KeyInvalidDateTime}
+	for _, err := range []error{storage.ErrPostNotFound, storage.ErrCommentNotFound,
+		storage.ErrParentCommentNotFound, storage.ErrCommentDeleted} {
+		var keyed service.MessageKeyer
+		require.ErrorAs(t, err, &keyed)
+		keys = append(keys, keyed.MessageKey())
+	}
+
+	for _, key := range keys {
+		for _, lang := range []i18n.Language{i18n.Russian, i18n.English} {
+			_, ok := messages[key][lang]
+			assert.True(t, ok, "нет сообщения %s для языка %s", key, lang)
+		}
+	}
+}
+
+func TestStreamErrors(t *testing.T) {
+	var ext StreamErrors
+	var opCtx context.Context
+	ext.InterceptOperation(i18n.NewContext(context.Background(), i18n.English), func(ctx context.Context) graphql.ResponseHandler {
+		opCtx = ctx
+		return nil
+	})
+
+	// пока сервер не закрыл подписку, после конца потока сразу complete
+	ended := func(context.Context) *graphql.Response { return nil }
+	respCtx := graphql.WithResponseContext(opCtx, Presenter, nil)
+	assert.Nil(t, ext.InterceptResponse(respCtx, ended))
+
+	// сервис подписок сообщает ошибку через обработчик из контекста резолвера
+	sub := subscription.NewInMemorySubscription(subscription.Options{BufferSize: 1, Overflow: subscription.Disconnect})
+	ch := sub.Subscribe(opCtx, 1)
+	for i := range 3 {
+		require.NoError(t, sub.Publish(1, &model.Comment{ID: i}))
+	}
+	for range ch {
+	}
+
+	resp := ext.InterceptResponse(respCtx, ended)
+	require.NotNil(t, resp)
+	require.Len(t, resp.Errors, 1)
+	assert.Equal(t, CodeSubscriberSlow, resp.Errors[0].Extensions["code"])
+	assert.Equal(t, "subscriber is too slow to receive events, subscription closed", resp.Errors[0].Message)
+
+	// ошибка отдается один раз, следующий вызов завершает подписку
+	assert.Nil(t, ext.InterceptResponse(graphql.WithResponseContext(opCtx, Presenter, nil), ended))
+}
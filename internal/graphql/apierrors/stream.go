@@ -0,0 +1,74 @@
+package apierrors
+
+import (
+	"OzonTestTask/internal/subscription"
+	"context"
+	"sync"
+
+	"github.com/99designs/gqlgen/graphql"
+)
+
+// StreamErrors Расширение сервера, через которое подписка завершается ошибкой.
+// Если сервис подписок закрывает канал с ошибкой (subscription.WithErrorHandler, например ErrSubscriberTooSlow),
+// клиент получает последнее событие с этой ошибкой (с кодом и переводом, как у остальных ошибок) и затем complete
+type StreamErrors struct{}
+
+var _ interface {
+	graphql.OperationInterceptor
+	graphql.ResponseInterceptor
+	graphql.HandlerExtension
+} = StreamErrors{}
+
+type streamErrorKey struct{}
+
+// streamError Ошибка подписки, которую еще не отдали клиенту
+type streamError struct {
+	mu  sync.Mutex
+	err error
+}
+
+func (StreamErrors) ExtensionName() string {
+	return "StreamErrors"
+}
+
+func (StreamErrors) Validate(schema graphql.ExecutableSchema) error {
+	return nil
+}
+
+func (StreamErrors) InterceptOperation(ctx context.Context, next graphql.OperationHandler) graphql.ResponseHandler {
+	holder := &streamError{}
+	ctx = context.WithValue(ctx, streamErrorKey{}, holder)
+	// контекст резолвера подписки производный от этого, поэтому сервис подписок найдет обработчик в нем
+	return next(subscription.WithErrorHandler(ctx, holder.set))
+}
+
+func (StreamErrors) InterceptResponse(ctx context.Context, next graphql.ResponseHandler) *graphql.Response {
+	resp := next(ctx)
+	if resp != nil {
+		return resp
+	}
+	// поток событий закончился: если подписку закрыл сервер, перед complete отдаю ошибку
+	if err := takeStreamError(ctx); err != nil {
+		graphql.AddError(ctx, err)
+		return &graphql.Response{Errors: graphql.GetErrors(ctx)}
+	}
+	return nil
+}
+
+func (h *streamError) set(err error) {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	h.err = err
+}
+
+func takeStreamError(ctx context.Context) error {
+	holder, ok := ctx.Value(streamErrorKey{}).(*streamError)
+	if !ok {
+		return nil
+	}
+	holder.mu.Lock()
+	defer holder.mu.Unlock()
+	err := holder.err
+	holder.err = nil
+	return err
+}
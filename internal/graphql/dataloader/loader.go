@@ -0,0 +1,99 @@
+package dataloader
+
+import (
+	"context"
+	"sync"
+	"time"
+)
+
+// batchTimeout Сколько может идти загрузка одной пачки
+const batchTimeout = 5 * time.Second
+
+// BatchFunc Загрузка значений сразу для пачки ключей.
+// Ключи, которых нет в ответе, получают нулевое значение
+type BatchFunc[K comparable, V any] func(ctx context.Context, keys []K) (map[K]V, error)
+
+// Loader Собирает ключи, запрошенные резолверами за короткое окно wait, и загружает их одним вызовом fetch.
+// Результаты между пачками не кэширую: загрузчик живет столько же, сколько HTTP-запрос,
+// а для websocket это все соединение, и кэш отдавал бы подписчику устаревшие данные
+type Loader[K comparable, V any] struct {
+	fetch    BatchFunc[K, V]
+	wait     time.Duration
+	maxBatch int
+
+	mu    sync.Mutex
+	batch *batch[K, V]
+}
+
+type batch[K comparable, V any] struct {
+	keys  []K
+	index map[K]struct{}
+	timer *time.Timer
+	once  sync.Once
+	done  chan struct{}
+
+	results map[K]V
+	err     error
+}
+
+func NewLoader[K comparable, V any](fetch BatchFunc[K, V], wait time.Duration, maxBatch int) *Loader[K, V] {
+	return &Loader[K, V]{
+		fetch:    fetch,
+		wait:     wait,
+		maxBatch: maxBatch,
+	}
+}
+
+// Load Значение по ключу. Блокируется, пока не будет загружена пачка, в которую попал ключ
+func (l *Loader[K, V]) Load(ctx context.Context, key K) (V, error) {
+	l.mu.Lock()
+	b := l.batch
+	if b == nil {
+		b = &batch[K, V]{
+			index: make(map[K]struct{}),
+			done:  make(chan struct{}),
+		}
+		b.timer = time.AfterFunc(l.wait, func() { l.dispatch(ctx, b) })
+		l.batch = b
+	}
+	if _, ok := b.index[key]; !ok {
+		b.index[key] = struct{}{}
+		b.keys = append(b.keys, key)
+	}
+	// пачка заполнена - отправляю сразу, не дожидаясь таймера
+	if l.maxBatch > 0 && len(b.keys) >= l.maxBatch {
+		l.batch = nil
+		b.timer.Stop()
+		go l.dispatch(ctx, b)
+	}
+	l.mu.Unlock()
+
+	var zero V
+	select {
+	case <-b.done:
+	case <-ctx.Done():
+		return zero, ctx.Err()
+	}
+	if b.err != nil {
+		return zero, b.err
+	}
+	return b.results[key], nil
+}
+
+// dispatch отправляет пачку ровно один раз, даже если таймер и переполнение сработали одновременно.
+// ctx - контекст первого вызвавшего Load: значения из него (язык и т.п.) нужны загрузке, но его отмена -
+// нет, иначе отмененный запрос или завершенная подписка websocket сорвали бы загрузку остальным ключам пачки
+func (l *Loader[K, V]) dispatch(ctx context.Context, b *batch[K, V]) {
+	b.once.Do(func() {
+		l.mu.Lock()
+		if l.batch == b {
+			l.batch = nil
+		}
+		l.mu.Unlock()
+
+		fetchCtx, cancel := context.WithTimeout(context.WithoutCancel(ctx), batchTimeout)
+		defer cancel()
+		b.results, b.err = l.fetch(fetchCtx, b.keys)
+		close(b.done)
+	})
+}
@@ -0,0 +1,123 @@
+package dataloader
+
+import (
+	"context"
+	"errors"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"slices"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+var ctx = context.Background()
+
+func TestLoader_BatchesConcurrentLoads(t *testing.T) {
+	var calls atomic.Int32
+	var gotKeys []int
+	loader := NewLoader(func(ctx context.Context, keys []int) (map[int]string, error) {
+		calls.Add(1)
+		gotKeys = slices.Clone(keys)
+		result := make(map[int]string, len(keys))
+		for _, k := range keys {
+			result[k] = string(rune('a' + k))
+		}
+		return result, nil
+	}, 10*time.Millisecond, 100)
+
+	keys := []int{1, 2, 3, 2, 1}
+	values := make([]string, len(keys))
+	var wg sync.WaitGroup
+	for i, key := range keys {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			v, err := loader.Load(ctx, key)
+			assert.NoError(t, err)
+			values[i] = v
+		}()
+	}
+	wg.Wait()
+
+	assert.Equal(t, int32(1), calls.Load(), "все ключи должны уйти одной пачкой")
+	slices.Sort(gotKeys)
+	assert.Equal(t, []int{1, 2, 3}, gotKeys, "повторяющиеся ключи загружаются один раз")
+	assert.Equal(t, []string{"b", "c", "d", "c", "b"}, values)
+}
+
+func TestLoader_MaxBatch(t *testing.T) {
+	var mu sync.Mutex
+	var sizes []int
+	loader := NewLoader(func(ctx context.Context, keys []int) (map[int]int, error) {
+		mu.Lock()
+		sizes = append(sizes, len(keys))
+		mu.Unlock()
+		return map[int]int{}, nil
+	}, 50*time.Millisecond, 2)
+
+	var wg sync.WaitGroup
+	for i := range 5 {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			v, err := loader.Load(ctx, i)
+			assert.NoError(t, err)
+			assert.Zero(t, v, "ключ без значения получает нулевое значение")
+		}()
+	}
+	wg.Wait()
+
+	slices.Sort(sizes)
+	assert.Equal(t, []int{1, 2, 2}, sizes)
+}
+
+func TestLoader_Error(t *testing.T) {
+	loader := NewLoader(func(ctx context.Context, keys []int) (map[int]int, error) {
+		return nil, errors.New("хранилище недоступно")
+	}, time.Millisecond, 100)
+
+	_, err := loader.Load(ctx, 1)
+	require.Error(t, err)
+
+	// ошибка не запоминается - следующая пачка снова идет в хранилище
+	_, err = loader.Load(ctx, 1)
+	require.Error(t, err)
+}
+
+func TestLoader_ContextCanceled(t *testing.T) {
+	loader := NewLoader(func(ctx context.Context, keys []int) (map[int]int, error) {
+		return map[int]int{1: 1}, nil
+	}, time.Second, 100)
+
+	cancelCtx, cancel := context.WithCancel(ctx)
+	cancel()
+	_, err := loader.Load(cancelCtx, 1)
+	require.ErrorIs(t, err, context.Canceled)
+}
+
+func TestLoader_FirstCallerCanceled(t *testing.T) {
+	loader := NewLoader(func(ctx context.Context, keys []int) (map[int]int, error) {
+		if err := ctx.Err(); err != nil {
+			return nil, err
+		}
+		return map[int]int{1: 10, 2: 20}, nil
+	}, 20*time.Millisecond, 100)
+
+	// пачку открывает запрос, который отменяется до ее отправки
+	firstCtx, cancel := context.WithCancel(ctx)
+	done := make(chan struct{})
+	go func() {
+		defer close(done)
+		_, err := loader.Load(firstCtx, 1)
+		assert.ErrorIs(t, err, context.Canceled)
+	}()
+	time.Sleep(5 * time.Millisecond)
+	cancel()
+	<-done
+
+	v, err := loader.Load(ctx, 2)
+	require.NoError(t, err, "отмена первого запроса не должна срывать загрузку остальных ключей пачки")
+	assert.Equal(t, 20, v)
+}
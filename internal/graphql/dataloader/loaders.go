@@ -0,0 +1,120 @@
+package dataloader
+
+import (
+	"OzonTestTask/internal/model"
+	"OzonTestTask/internal/service"
+	"context"
+	"net/http"
+	"time"
+)
+
+const (
+	batchWait = 2 * time.Millisecond
+	maxBatch  = 100
+)
+
+type ctxKey struct{}
+
+// CommentsKey Ключ страницы корневых комментариев поста. limit и offset входят в ключ,
+// потому что в одном запросе у разных постов они могут отличаться
+type CommentsKey struct {
+	PostID int
+	Limit  int
+	Offset int
+}
+
+// Loaders Набор загрузчиков одного запроса
+type Loaders struct {
+	RootComments  *Loader[CommentsKey, model.PaginatedComments]
+	CommentCounts *Loader[int, model.CommentCounts]
+	Revisions     *Loader[int, []model.CommentRevision]
+	// Replies - прямые ответы по id родителя для Comment.children вне commentTree
+	Replies *Loader[int, []model.Comment]
+	// Posts и Comments - объекты по id для Comment.post и Comment.parent, не найденные - nil
+	Posts    *Loader[int, *model.Post]
+	Comments *Loader[int, *model.Comment]
+}
+
+func NewLoaders(posts service.PostService, comments service.CommentService) *Loaders {
+	return &Loaders{
+		RootComments:  NewLoader(rootCommentsBatch(comments), batchWait, maxBatch),
+		CommentCounts: NewLoader(comments.GetCommentCounts, batchWait, maxBatch),
+		Revisions:     NewLoader(comments.GetRevisionsByComments, batchWait, maxBatch),
+		Replies:       NewLoader(comments.GetRepliesByParents, batchWait, maxBatch),
+		// сервисы вызываются через замыкания, а не method value: в тестах резолверов
+		// PostService может быть не задан, и создание загрузчиков не должно на этом падать
+		Posts: NewLoader(byIDBatch(func(ctx context.Context, ids []int) (map[int]model.Post, error) {
+			return posts.GetPostsByIDs(ctx, ids)
+		}), batchWait, maxBatch),
+		Comments: NewLoader(byIDBatch(func(ctx context.Context, ids []int) (map[int]model.Comment, error) {
+			return comments.GetCommentsByIDs(ctx, ids)
+		}), batchWait, maxBatch),
+	}
+}
+
+// Middleware Кладет в контекст каждого запроса свежий набор загрузчиков
+func Middleware(posts service.PostService, comments service.CommentService, next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		ctx := NewContext(r.Context(), NewLoaders(posts, comments))
+		next.ServeHTTP(w, r.WithContext(ctx))
+	})
+}
+
+func NewContext(ctx context.Context, loaders *Loaders) context.Context {
+	return context.WithValue(ctx, ctxKey{}, loaders)
+}
+
+// For Загрузчики текущего запроса, nil - если запрос не прошел через Middleware
+func For(ctx context.Context) *Loaders {
+	loaders, _ := ctx.Value(ctxKey{}).(*Loaders)
+	return loaders
+}
+
+// rootCommentsBatch группирует ключи по (limit, offset) - обычно у всех постов в списке они одинаковые,
+// и тогда на всю пачку уходит один запрос в хранилище
+func rootCommentsBatch(comments service.CommentService) BatchFunc[CommentsKey, model.PaginatedComments] {
+	return func(ctx context.Context, keys []CommentsKey) (map[CommentsKey]model.PaginatedComments, error) {
+		type pageArgs struct{ limit, offset int }
+		groups := make(map[pageArgs][]int)
+		for _, key := range keys {
+			args := pageArgs{key.Limit, key.Offset}
+			groups[args] = append(groups[args], key.PostID)
+		}
+
+		result := make(map[CommentsKey]model.PaginatedComments, len(keys))
+		for args, postIDs := range groups {
+			byPost, totalPages, err := comments.GetCommentsByPosts(ctx, postIDs, args.limit, args.offset)
+			if err != nil {
+				return nil, err
+			}
+			for _, postID := range postIDs {
+				postComments := byPost[postID]
+				page := model.PaginatedComments{
+					Comments:   make([]*model.Comment, len(postComments)),
+					TotalPages: totalPages[postID],
+				}
+				for i := range postComments {
+					page.Comments[i] = &postComments[i]
+				}
+				result[CommentsKey{PostID: postID, Limit: args.limit, Offset: args.offset}] = page
+			}
+		}
+		return result, nil
+	}
+}
+
+// byIDBatch загрузка объектов по id: значения из хранилища отдаю указателями,
+// чтобы не найденный id отличался от найденного (nil вместо нулевого объекта)
+func byIDBatch[V any](fetch func(ctx context.Context, ids []int) (map[int]V, error)) BatchFunc[int, *V] {
+	return func(ctx context.Context, ids []int) (map[int]*V, error) {
+		values, err := fetch(ctx, ids)
+		if err != nil {
+			return nil, err
+		}
+		result := make(map[int]*V, len(values))
+		for id, value := range values {
+			result[id] = &value
+		}
+		return result, nil
+	}
+}
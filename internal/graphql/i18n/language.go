@@ -0,0 +1,78 @@
+package i18n
+
+import (
+	"context"
+	"net/http"
+
+	"github.com/99designs/gqlgen/graphql/handler/transport"
+	"golang.org/x/text/language"
+)
+
+// Language Язык сообщений в ответе
+type Language string
+
+const (
+	Russian Language = "ru"
+	English Language = "en"
+
+	// Default язык, если клиент его не указал или указал неподдерживаемый
+	Default = Russian
+)
+
+// supported порядок совпадает с тегами matcher: по индексу совпадения выбираю язык
+var (
+	supported = []Language{Russian, English}
+	matcher   = language.NewMatcher([]language.Tag{language.Russian, language.English})
+)
+
+// Negotiate Язык ответа по значению Accept-Language (например, "en-US,en;q=0.9,ru;q=0.8")
+// с учетом весов q. Если ни один язык не поддерживается - Default
+func Negotiate(acceptLanguage string) Language {
+	if acceptLanguage == "" {
+		return Default
+	}
+	tags, _, err := language.ParseAcceptLanguage(acceptLanguage)
+	if err != nil || len(tags) == 0 {
+		return Default
+	}
+	_, index, confidence := matcher.Match(tags...)
+	if confidence == language.No {
+		return Default
+	}
+	return supported[index]
+}
+
+type ctxKey struct{}
+
+func NewContext(ctx context.Context, lang Language) context.Context {
+	return context.WithValue(ctx, ctxKey{}, lang)
+}
+
+// FromContext Язык текущего запроса, Default - если он не выбирался
+func FromContext(ctx context.Context) Language {
+	if lang, ok := ctx.Value(ctxKey{}).(Language); ok {
+		return lang
+	}
+	return Default
+}
+
+// Middleware Выбор языка по заголовку Accept-Language для каждого HTTP-запроса,
+// в том числе для запроса на открытие websocket
+func Middleware(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		lang := Negotiate(r.Header.Get("Accept-Language"))
+		next.ServeHTTP(w, r.WithContext(NewContext(r.Context(), lang)))
+	})
+}
+
+// WebsocketInit InitFunc для websocket-транспорта. Браузер не дает задать заголовки websocket-запроса,
+// поэтому язык можно передать в payload connection_init: {"language": "en"} или {"Accept-Language": "en"}.
+// Если в payload языка нет, остается выбранный по заголовку запроса на открытие соединения
+func WebsocketInit(ctx context.Context, payload transport.InitPayload) (context.Context, *transport.InitPayload, error) {
+	for _, key := range []string{"language", "Accept-Language"} {
+		if value := payload.GetString(key); value != "" {
+			return NewContext(ctx, Negotiate(value)), nil, nil
+		}
+	}
+	return ctx, nil, nil
+}
@@ -0,0 +1,57 @@
+package i18n
+
+import (
+	"context"
+	"github.com/99designs/gqlgen/graphql/handler/transport"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+)
+
+func TestNegotiate(t *testing.T) {
+	tests := []struct {
+		header string
+		want   Language
+	}{
+		{"", Russian},
+		{"en", English},
+		{"en-US,en;q=0.9", English},
+		{"ru-RU,ru;q=0.9,en;q=0.8", Russian},
+		{"de;q=1.0,en;q=0.5,ru;q=0.3", English},
+		{"de, fr", Russian},
+		{"не заголовок;q=abc", Russian},
+	}
+	for _, tt := range tests {
+		assert.Equal(t, tt.want, Negotiate(tt.header), tt.header)
+	}
+}
+
+func TestMiddleware(t *testing.T) {
+	var got Language
+	handler := Middleware(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		got = FromContext(r.Context())
+	}))
+
+	req := httptest.NewRequest(http.MethodPost, "/graphql", nil)
+	req.Header.Set("Accept-Language", "en-GB")
+	handler.ServeHTTP(httptest.NewRecorder(), req)
+	assert.Equal(t, English, got)
+
+	handler.ServeHTTP(httptest.NewRecorder(), httptest.NewRequest(http.MethodPost, "/graphql", nil))
+	assert.Equal(t, Russian, got)
+}
+
+func TestWebsocketInit(t *testing.T) {
+	ctx := NewContext(context.Background(), Russian)
+
+	newCtx, _, err := WebsocketInit(ctx, transport.InitPayload{"language": "en"})
+	require.NoError(t, err)
+	assert.Equal(t, English, FromContext(newCtx))
+
+	// без языка в payload остается язык из заголовка запроса на открытие соединения
+	newCtx, _, err = WebsocketInit(ctx, transport.InitPayload{})
+	require.NoError(t, err)
+	assert.Equal(t, Russian, FromContext(newCtx))
+}
@@ -0,0 +1,92 @@
+package limits
+
+import (
+	"OzonTestTask/internal/graphql/generated"
+	"OzonTestTask/internal/model"
+	"OzonTestTask/internal/pagination"
+	"time"
+)
+
+// Costs Базовая стоимость дорогих полей. К ней добавляется стоимость дочерних полей,
+// умноженная на ожидаемое количество элементов списка
+type Costs struct {
+	Comments int // Post.comments и Post.commentsConnection
+	Replies  int // Query.replies и Query.commentTree (в дереве - за каждый загружаемый уровень)
+	List     int // остальные списки: посты, ответы, предки, история изменений, nodes
+	// ListSize - сколько элементов считать в списках без лимита (posts, children, ancestors, revisions)
+	ListSize int
+	// TreeDepth - сколько уровней считать в replies и commentTree без maxDepth: загружается вся ветка
+	TreeDepth int
+}
+
+var DefaultCosts = Costs{
+	Comments:  10,
+	Replies:   10,
+	List:      5,
+	ListSize:  pagination.DefaultLimit,
+	TreeDepth: 20,
+}
+
+// defaultCommentsLimit совпадает с limit по умолчанию в резолвере Post.comments
+const defaultCommentsLimit = 5
+
+// Complexity Функции подсчета сложности для generated.Config. Поля, которых здесь нет,
+// считаются по умолчанию gqlgen: 1 + сложность дочерних полей
+func Complexity(costs Costs) generated.ComplexityRoot {
+	var c generated.ComplexityRoot
+
+	c.Post.Comments = func(childComplexity int, limit *int, offset *int) int {
+		return costs.Comments + size(limit, defaultCommentsLimit)*childComplexity
+	}
+	c.Post.CommentsConnection = func(childComplexity int, first *int, after *string, last *int, before *string) int {
+		return costs.Comments + pageSize(first, last)*childComplexity
+	}
+
+	// без first ответы не ограничены, поэтому считаю по максимальной странице. Каждый прямой ответ приходит
+	// со своей веткой до maxDepth, поэтому и базовая стоимость, и число комментариев умножаются на глубину
+	// (без maxDepth ветка загружается целиком - считаю TreeDepth уровней, как в commentTree)
+	c.Query.Replies = func(childComplexity int, id string, maxDepth *int, first *int, after *string) int {
+		depth := size(maxDepth, costs.TreeDepth)
+		return costs.Replies*depth + size(first, pagination.MaxLimit)*depth*childComplexity
+	}
+	// ветка загружается целиком до maxDepth, независимо от того, сколько уровней children выбрано в запросе
+	c.Query.CommentTree = func(childComplexity int, rootID string, maxDepth *int) int {
+		return costs.Replies*size(maxDepth, costs.TreeDepth) + childComplexity
+	}
+	c.Query.Nodes = func(childComplexity int, ids []string) int {
+		return costs.List + len(ids)*childComplexity
+	}
+
+	c.Query.Posts = func(childComplexity int, orderBy *model.PostOrder, createdAfter *time.Time) int {
+		return costs.List + costs.ListSize*childComplexity
+	}
+	c.Query.PostsConnection = func(childComplexity int, first *int, after *string, last *int, before *string) int {
+		return costs.List + pageSize(first, last)*childComplexity
+	}
+	c.Comment.Children = func(childComplexity int) int {
+		return costs.List + costs.ListSize*childComplexity
+	}
+	c.Comment.Ancestors = func(childComplexity int) int {
+		return costs.List + costs.ListSize*childComplexity
+	}
+	c.Comment.Revisions = func(childComplexity int) int {
+		return costs.List + costs.ListSize*childComplexity
+	}
+	return c
+}
+
+// size количество элементов из аргумента-лимита, если он не задан - значение по умолчанию
+func size(limit *int, defaultSize int) int {
+	if limit == nil || *limit <= 0 {
+		return defaultSize
+	}
+	return *limit
+}
+
+// pageSize размер страницы курсорной пагинации по first/last, как в pagination.NewPage
+func pageSize(first, last *int) int {
+	if first != nil {
+		return size(first, pagination.DefaultLimit)
+	}
+	return size(last, pagination.DefaultLimit)
+}
@@ -0,0 +1,128 @@
+package limits
+
+import (
+	"OzonTestTask/internal/graphql/generated"
+	"OzonTestTask/internal/graphql/resolvers"
+	"OzonTestTask/internal/mocks"
+	"OzonTestTask/internal/model"
+	"encoding/json"
+	"github.com/99designs/gqlgen/graphql/handler"
+	"github.com/99designs/gqlgen/graphql/handler/extension"
+	"github.com/99designs/gqlgen/graphql/handler/transport"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/mock"
+	"github.com/stretchr/testify/require"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+)
+
+type response struct {
+	Data   json.RawMessage `json:"data"`
+	Errors []struct {
+		Message    string         `json:"message"`
+		Extensions map[string]any `json:"extensions"`
+	} `json:"errors"`
+}
+
+func newServer(postService *mocks.PostService, commentService *mocks.CommentService, maxComplexity, maxDepth int) *handler.Server {
+	server := handler.New(generated.NewExecutableSchema(generated.Config{
+		Resolvers:  &resolvers.Resolver{PostService: postService, CommentService: commentService},
+		Complexity: Complexity(DefaultCosts),
+	}))
+	server.AddTransport(transport.POST{})
+	server.Use(extension.FixedComplexityLimit(maxComplexity))
+	server.Use(DepthLimit{MaxDepth: maxDepth})
+	return server
+}
+
+func doQuery(t *testing.T, server http.Handler, query string) response {
+	body, err := json.Marshal(map[string]string{"query": query})
+	require.NoError(t, err)
+
+	req := httptest.NewRequest(http.MethodPost, "/graphql", strings.NewReader(string(body)))
+	req.Header.Set("Content-Type", "application/json")
+	rec := httptest.NewRecorder()
+	server.ServeHTTP(rec, req)
+
+	var resp response
+	require.NoError(t, json.Unmarshal(rec.Body.Bytes(), &resp))
+	return resp
+}
+
+func TestComplexityLimit_RejectsBeforeStorage(t *testing.T) {
+	postService := new(mocks.PostService)
+	commentService := new(mocks.CommentService)
+	server := newServer(postService, commentService, 1000, 10)
+
+	// 10 постов по умолчанию * 1000 комментариев - далеко за пределами бюджета
+	resp := doQuery(t, server, `{ posts { id comments(limit: 1000) { comments { id content } } } }`)
+	require.Len(t, resp.Errors, 1)
+	assert.Equal(t, "COMPLEXITY_LIMIT_EXCEEDED", resp.Errors[0].Extensions["code"])
+
+	postService.AssertNotCalled(t, "GetAllPosts", mock.Anything, mock.Anything)
+	commentService.AssertNotCalled(t, "GetCommentsByPosts", mock.Anything, mock.Anything, mock.Anything, mock.Anything)
+}
+
+func TestComplexityLimit_AllowsCheapQuery(t *testing.T) {
+	postService := new(mocks.PostService)
+	commentService := new(mocks.CommentService)
+	server := newServer(postService, commentService, 1000, 10)
+
+	postService.On("GetAllPosts", mock.Anything, model.PostsFilter{Order: model.PostOrderCreatedAt}).
+		Return([]model.Post{{ID: 1, Title: "Пост"}}, nil)
+
+	resp := doQuery(t, server, `{ posts { databaseId title } }`)
+	require.Empty(t, resp.Errors)
+	assert.JSONEq(t, `{"posts":[{"databaseId":1,"title":"Пост"}]}`, string(resp.Data))
+	postService.AssertExpectations(t)
+}
+
+func TestDepthLimit(t *testing.T) {
+	postService := new(mocks.PostService)
+	commentService := new(mocks.CommentService)
+	server := newServer(postService, commentService, 100000, 3)
+
+	// posts -> comments -> comments -> id: глубина 4
+	resp := doQuery(t, server, `{ posts { comments { comments { id } } } }`)
+	require.Len(t, resp.Errors, 1)
+	assert.Equal(t, "DEPTH_LIMIT_EXCEEDED", resp.Errors[0].Extensions["code"])
+	postService.AssertNotCalled(t, "GetAllPosts", mock.Anything, mock.Anything)
+
+	// фрагменты уровня не добавляют
+	resp = doQuery(t, server, `{ ...f } fragment f on Query { posts { comments { comments { id } } } }`)
+	require.Len(t, resp.Errors, 1)
+	assert.Equal(t, "DEPTH_LIMIT_EXCEEDED", resp.Errors[0].Extensions["code"])
+}
+
+func TestComplexityLimit_RepliesWithoutDepth(t *testing.T) {
+	postService := new(mocks.PostService)
+	commentService := new(mocks.CommentService)
+	server := newServer(postService, commentService, 1000, 10)
+
+	// вся ветка под каждым из 100 прямых ответов
+	resp := doQuery(t, server, `{ replies(id: "1") { id content } }`)
+	require.Len(t, resp.Errors, 1)
+	assert.Equal(t, "COMPLEXITY_LIMIT_EXCEEDED", resp.Errors[0].Extensions["code"])
+	commentService.AssertNotCalled(t, "GetReplies", mock.Anything, mock.Anything, mock.Anything)
+}
+
+func TestComplexityWeightedByLimit(t *testing.T) {
+	c := Complexity(DefaultCosts)
+	small, large := 1, 50
+
+	assert.Less(t, c.Post.Comments(3, &small, nil), c.Post.Comments(3, &large, nil))
+	assert.Equal(t, DefaultCosts.Comments+defaultCommentsLimit*3, c.Post.Comments(3, nil, nil))
+
+	// каждый прямой ответ приходит с веткой до maxDepth, без maxDepth - TreeDepth уровней
+	assert.Equal(t, DefaultCosts.Replies+large*2, c.Query.Replies(2, "1", &small, &large, nil))
+	assert.Equal(t, DefaultCosts.Replies*DefaultCosts.TreeDepth+small*DefaultCosts.TreeDepth*2, c.Query.Replies(2, "1", nil, &small, nil))
+	assert.Less(t, c.Query.Replies(2, "1", &small, &small, nil), c.Query.Replies(2, "1", nil, &small, nil))
+
+	// дерево без maxDepth загружается целиком и стоит как TreeDepth уровней
+	assert.Less(t, c.Query.CommentTree(3, "1", &small), c.Query.CommentTree(3, "1", &large))
+	assert.Equal(t, DefaultCosts.Replies*DefaultCosts.TreeDepth+3, c.Query.CommentTree(3, "1", nil))
+
+	assert.Equal(t, DefaultCosts.List+3*2, c.Query.Nodes(2, []string{"a", "b", "c"}))
+}
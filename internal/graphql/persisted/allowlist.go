@@ -0,0 +1,64 @@
+package persisted
+
+import (
+	"context"
+	"errors"
+
+	"github.com/99designs/gqlgen/graphql"
+	"github.com/99designs/gqlgen/graphql/errcode"
+	"github.com/vektah/gqlparser/v2/gqlerror"
+)
+
+const errNotAllowed = "PERSISTED_QUERY_NOT_ALLOWED"
+
+// Allowlist Строгий режим: выполняются только операции из манифеста.
+// Клиент может прислать только хэш (как в APQ) - текст запроса подставляется из манифеста,
+// или полный текст - тогда он должен совпадать с зарегистрированным до символа
+type Allowlist struct {
+	Manifest *Manifest
+}
+
+var _ interface {
+	graphql.OperationParameterMutator
+	graphql.HandlerExtension
+} = Allowlist{}
+
+func (a Allowlist) ExtensionName() string {
+	return "Allowlist"
+}
+
+func (a Allowlist) Validate(schema graphql.ExecutableSchema) error {
+	if a.Manifest == nil {
+		return errors.New("Allowlist.Manifest не может быть nil")
+	}
+	return nil
+}
+
+func (a Allowlist) MutateOperationParameters(ctx context.Context, rawParams *graphql.RawParams) *gqlerror.Error {
+	if rawParams.Query == "" {
+		query, ok := a.Manifest.Get(persistedQueryHash(rawParams))
+		if !ok {
+			return notAllowed()
+		}
+		rawParams.Query = query
+		return nil
+	}
+
+	if _, ok := a.Manifest.Get(QueryHash(rawParams.Query)); !ok {
+		return notAllowed()
+	}
+	return nil
+}
+
+// persistedQueryHash хэш из extensions.persistedQuery.sha256Hash, как его присылают клиенты APQ
+func persistedQueryHash(rawParams *graphql.RawParams) string {
+	ext, _ := rawParams.Extensions["persistedQuery"].(map[string]any)
+	hash, _ := ext["sha256Hash"].(string)
+	return hash
+}
+
+func notAllowed() *gqlerror.Error {
+	err := gqlerror.Errorf("запрос не зарегистрирован в списке разрешенных")
+	errcode.Set(err, errNotAllowed)
+	return err
+}
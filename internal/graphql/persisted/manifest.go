@@ -0,0 +1,72 @@
+package persisted
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"os"
+)
+
+// Manifest Заранее зарегистрированные операции: sha256 текста запроса -> текст запроса.
+// Формат файла совпадает с манифестом persisted queries Apollo:
+//
+//	{"format": "apollo-persisted-query-manifest", "version": 1,
+//	 "operations": [{"id": "<sha256>", "name": "GetPosts", "type": "query", "body": "query GetPosts { ... }"}]}
+type Manifest struct {
+	operations map[string]string
+}
+
+type manifestFile struct {
+	Format     string `json:"format"`
+	Version    int    `json:"version"`
+	Operations []struct {
+		ID   string `json:"id"`
+		Name string `json:"name"`
+		Type string `json:"type"`
+		Body string `json:"body"`
+	} `json:"operations"`
+}
+
+// LoadManifest Чтение манифеста. Хэш каждой операции проверяю при загрузке,
+// чтобы ошибка в манифесте обнаружилась при старте сервера, а не на запросе клиента
+func LoadManifest(path string) (*Manifest, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, fmt.Errorf("не удалось прочитать манифест запросов: %v", err)
+	}
+
+	var file manifestFile
+	if err = json.Unmarshal(data, &file); err != nil {
+		return nil, fmt.Errorf("некорректный манифест запросов: %v", err)
+	}
+	if file.Version != 1 {
+		return nil, fmt.Errorf("неподдерживаемая версия манифеста запросов: %d", file.Version)
+	}
+
+	m := &Manifest{operations: make(map[string]string, len(file.Operations))}
+	for _, op := range file.Operations {
+		if hash := QueryHash(op.Body); hash != op.ID {
+			return nil, fmt.Errorf("хэш операции %s не совпадает с ее текстом: ожидался %s", op.Name, hash)
+		}
+		m.operations[op.ID] = op.Body
+	}
+	return m, nil
+}
+
+// Get Текст зарегистрированного запроса по хэшу
+func (m *Manifest) Get(hash string) (string, bool) {
+	query, ok := m.operations[hash]
+	return query, ok
+}
+
+// Len Количество зарегистрированных операций
+func (m *Manifest) Len() int {
+	return len(m.operations)
+}
+
+// QueryHash sha256 текста запроса в hex - так же считают хэш клиенты APQ
+func QueryHash(query string) string {
+	sum := sha256.Sum256([]byte(query))
+	return hex.EncodeToString(sum[:])
+}
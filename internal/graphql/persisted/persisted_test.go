@@ -0,0 +1,124 @@
+package persisted
+
+import (
+	"OzonTestTask/internal/graphql/generated"
+	"OzonTestTask/internal/graphql/resolvers"
+	"OzonTestTask/internal/mocks"
+	"OzonTestTask/internal/model"
+	"encoding/json"
+	"fmt"
+	"github.com/99designs/gqlgen/graphql/handler"
+	"github.com/99designs/gqlgen/graphql/handler/transport"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/mock"
+	"github.com/stretchr/testify/require"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+const getPosts = `query GetPosts { posts { databaseId title } }`
+
+type response struct {
+	Data   json.RawMessage `json:"data"`
+	Errors []struct {
+		Message    string         `json:"message"`
+		Extensions map[string]any `json:"extensions"`
+	} `json:"errors"`
+}
+
+func writeManifest(t *testing.T, id, body string) string {
+	manifest := map[string]any{
+		"format":  "apollo-persisted-query-manifest",
+		"version": 1,
+		"operations": []map[string]string{
+			{"id": id, "name": "GetPosts", "type": "query", "body": body},
+		},
+	}
+	data, err := json.Marshal(manifest)
+	require.NoError(t, err)
+
+	path := filepath.Join(t.TempDir(), "manifest.json")
+	require.NoError(t, os.WriteFile(path, data, 0o600))
+	return path
+}
+
+func doRequest(t *testing.T, server http.Handler, params map[string]any) response {
+	body, err := json.Marshal(params)
+	require.NoError(t, err)
+
+	req := httptest.NewRequest(http.MethodPost, "/graphql", strings.NewReader(string(body)))
+	req.Header.Set("Content-Type", "application/json")
+	rec := httptest.NewRecorder()
+	server.ServeHTTP(rec, req)
+
+	var resp response
+	require.NoError(t, json.Unmarshal(rec.Body.Bytes(), &resp))
+	return resp
+}
+
+func TestLoadManifest(t *testing.T) {
+	manifest, err := LoadManifest(writeManifest(t, QueryHash(getPosts), getPosts))
+	require.NoError(t, err)
+	assert.Equal(t, 1, manifest.Len())
+
+	query, ok := manifest.Get(QueryHash(getPosts))
+	require.True(t, ok)
+	assert.Equal(t, getPosts, query)
+}
+
+func TestLoadManifest_HashMismatch(t *testing.T) {
+	_, err := LoadManifest(writeManifest(t, QueryHash("query Other { posts { id } }"), getPosts))
+	require.Error(t, err)
+}
+
+func TestAllowlist(t *testing.T) {
+	manifest, err := LoadManifest(writeManifest(t, QueryHash(getPosts), getPosts))
+	require.NoError(t, err)
+
+	postService := new(mocks.PostService)
+	postService.On("GetAllPosts", mock.Anything, model.PostsFilter{Order: model.PostOrderCreatedAt}).
+		Return([]model.Post{{ID: 1, Title: "Пост"}}, nil)
+
+	server := handler.New(generated.NewExecutableSchema(generated.Config{
+		Resolvers: &resolvers.Resolver{PostService: postService},
+	}))
+	server.AddTransport(transport.POST{})
+	server.Use(Allowlist{Manifest: manifest})
+
+	t.Run("только хэш", func(t *testing.T) {
+		resp := doRequest(t, server, map[string]any{
+			"extensions": map[string]any{
+				"persistedQuery": map[string]any{"version": 1, "sha256Hash": QueryHash(getPosts)},
+			},
+		})
+		require.Empty(t, resp.Errors)
+		assert.JSONEq(t, `{"posts":[{"databaseId":1,"title":"Пост"}]}`, string(resp.Data))
+	})
+
+	t.Run("зарегистрированный текст", func(t *testing.T) {
+		resp := doRequest(t, server, map[string]any{"query": getPosts})
+		require.Empty(t, resp.Errors)
+	})
+
+	t.Run("незарегистрированный текст", func(t *testing.T) {
+		resp := doRequest(t, server, map[string]any{"query": `{ posts { id title content } }`})
+		require.Len(t, resp.Errors, 1)
+		assert.Equal(t, errNotAllowed, resp.Errors[0].Extensions["code"])
+	})
+
+	t.Run("неизвестный хэш", func(t *testing.T) {
+		resp := doRequest(t, server, map[string]any{
+			"extensions": map[string]any{
+				"persistedQuery": map[string]any{"version": 1, "sha256Hash": fmt.Sprintf("%064d", 0)},
+			},
+		})
+		require.Len(t, resp.Errors, 1)
+		assert.Equal(t, errNotAllowed, resp.Errors[0].Extensions["code"])
+	})
+
+	postService.AssertNumberOfCalls(t, "GetAllPosts", 2)
+}
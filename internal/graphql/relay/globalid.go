@@ -0,0 +1,55 @@
+package relay
+
+import (
+	"encoding/base64"
+	"fmt"
+	"strconv"
+	"strings"
+)
+
+// Типы объектов, реализующих интерфейс Node. Совпадают с именами типов в схеме
+const (
+	TypePost    = "Post"
+	TypeComment = "Comment"
+)
+
+// ToGlobalID Глобальный id объекта: base64 от "Тип:id". У постов и комментариев id в хранилище
+// пересекаются, а с префиксом типа id уникален во всей схеме и по нему можно найти объект через node(id)
+func ToGlobalID(typeName string, id int) string {
+	return base64.RawURLEncoding.EncodeToString([]byte(typeName + ":" + strconv.Itoa(id)))
+}
+
+// FromGlobalID Разбор глобального id на тип объекта и id в хранилище
+func FromGlobalID(globalID string) (string, int, error) {
+	raw, err := base64.RawURLEncoding.DecodeString(globalID)
+	if err != nil {
+		return "", 0, fmt.Errorf("некорректный id: %q", globalID)
+	}
+
+	typeName, rawID, ok := strings.Cut(string(raw), ":")
+	if !ok || typeName == "" {
+		return "", 0, fmt.Errorf("некорректный id: %q", globalID)
+	}
+	id, err := strconv.Atoi(rawID)
+	if err != nil || id <= 0 {
+		return "", 0, fmt.Errorf("некорректный id: %q", globalID)
+	}
+	return typeName, id, nil
+}
+
+// ParseID id в хранилище для аргумента с объектом ожидаемого типа.
+// Для обратной совместимости со старыми клиентами принимается и числовой id
+func ParseID(id string, typeName string) (int, error) {
+	if intID, err := strconv.Atoi(id); err == nil {
+		return intID, nil
+	}
+
+	gotType, intID, err := FromGlobalID(id)
+	if err != nil {
+		return 0, err
+	}
+	if gotType != typeName {
+		return 0, fmt.Errorf("id %q принадлежит объекту %s, ожидался %s", id, gotType, typeName)
+	}
+	return intID, nil
+}
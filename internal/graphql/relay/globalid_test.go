@@ -0,0 +1,37 @@
+package relay
+
+import (
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"testing"
+)
+
+func TestGlobalID(t *testing.T) {
+	postID := ToGlobalID(TypePost, 1)
+	commentID := ToGlobalID(TypeComment, 1)
+	assert.NotEqual(t, postID, commentID)
+
+	typeName, id, err := FromGlobalID(commentID)
+	require.NoError(t, err)
+	assert.Equal(t, TypeComment, typeName)
+	assert.Equal(t, 1, id)
+
+	for _, wrong := range []string{"", "abc!", ToGlobalID("", 1), "UG9zdA", "UG9zdDphYmM"} {
+		_, _, err = FromGlobalID(wrong)
+		assert.Error(t, err, wrong)
+	}
+}
+
+func TestParseID(t *testing.T) {
+	id, err := ParseID(ToGlobalID(TypePost, 7), TypePost)
+	require.NoError(t, err)
+	assert.Equal(t, 7, id)
+
+	// старые клиенты присылают числовой id
+	id, err = ParseID("7", TypePost)
+	require.NoError(t, err)
+	assert.Equal(t, 7, id)
+
+	_, err = ParseID(ToGlobalID(TypeComment, 7), TypePost)
+	assert.Error(t, err)
+}
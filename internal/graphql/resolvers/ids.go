@@ -0,0 +1,19 @@
+package resolvers
+
+import (
+	"OzonTestTask/internal/graphql/relay"
+	"OzonTestTask/internal/pagination"
+	"OzonTestTask/internal/service"
+)
+
+// maxNodes Сколько объектов можно запросить через nodes(ids) за раз
+const maxNodes = pagination.MaxLimit
+
+// parseID id в хранилище из аргумента field. Некорректный id - ошибка валидации этого аргумента
+func parseID(field, id, typeName string) (int, error) {
+	intID, err := relay.ParseID(id, typeName)
+	if err != nil {
+		return 0, service.NewValidationError(field, service.KeyInvalidID, err.Error())
+	}
+	return intID, nil
+}
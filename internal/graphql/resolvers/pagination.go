@@ -0,0 +1,37 @@
+package resolvers
+
+import (
+	"OzonTestTask/internal/graphql/generated"
+	"OzonTestTask/internal/pagination"
+	"OzonTestTask/internal/service"
+	"errors"
+)
+
+// newPageInfo Формирование pageInfo для connection-ответа.
+// hasMore - есть ли элементы дальше в направлении пагинации (first - вперед, last - назад)
+func newPageInfo(page pagination.Page, hasMore bool, cursors []string) *generated.PageInfo {
+	info := &generated.PageInfo{
+		HasNextPage:     !page.Backward && hasMore,
+		HasPreviousPage: page.Backward && hasMore,
+	}
+	if len(cursors) > 0 {
+		info.StartCursor = &cursors[0]
+		info.EndCursor = &cursors[len(cursors)-1]
+	}
+	return info
+}
+
+// paginationError Ошибка разбора аргументов пагинации как ошибка валидации с ключом сообщения
+func paginationError(err error) error {
+	switch {
+	case errors.Is(err, pagination.ErrInvalidCursor):
+		return service.NewValidationError("", service.KeyInvalidCursor, err.Error())
+	case errors.Is(err, pagination.ErrFirstAndLast):
+		return service.NewValidationError("last", service.KeyFirstAndLast, err.Error())
+	case errors.Is(err, pagination.ErrPageSize):
+		return service.NewValidationError("", service.KeyInvalidPageSize, err.Error())
+	case errors.Is(err, pagination.ErrMaxDepth):
+		return service.NewValidationError("maxDepth", service.KeyInvalidMaxDepth, err.Error())
+	}
+	return err
+}
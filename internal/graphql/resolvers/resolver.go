@@ -1,8 +1,10 @@
 package resolvers
 
 import (
+	"OzonTestTask/internal/graphql/dataloader"
 	"OzonTestTask/internal/service"
 	"OzonTestTask/internal/subscription"
+	"context"
 )
 
 // This file will not be regenerated automatically.
@@ -14,3 +16,12 @@ type Resolver struct {
 	CommentService      service.CommentService
 	SubscriptionService subscription.Subscription
 }
+
+// loaders Загрузчики текущего запроса. Если запрос пришел мимо dataloader.Middleware (например, в тестах),
+// создаю новые - тогда каждый вызов уходит в хранилище отдельной пачкой из одного ключа
+func (r *Resolver) loaders(ctx context.Context) *dataloader.Loaders {
+	if loaders := dataloader.For(ctx); loaders != nil {
+		return loaders
+	}
+	return dataloader.NewLoaders(r.PostService, r.CommentService)
+}
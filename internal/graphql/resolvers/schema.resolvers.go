@@ -5,22 +5,30 @@ package resolvers
 // Code generated by github.com/99designs/gqlgen version v0.17.81
 
 import (
+	"OzonTestTask/internal/graphql/dataloader"
 	"OzonTestTask/internal/graphql/generated"
+	"OzonTestTask/internal/graphql/relay"
 	"OzonTestTask/internal/model"
+	"OzonTestTask/internal/pagination"
+	"OzonTestTask/internal/service"
 	"context"
 	"fmt"
-	"strconv"
+	"sync"
 	"time"
+
+	"github.com/99designs/gqlgen/graphql"
+	"github.com/vektah/gqlparser/v2/ast"
+	"github.com/vektah/gqlparser/v2/gqlerror"
 )
 
 // ID is the resolver for the id field.
 func (r *commentResolver) ID(ctx context.Context, obj *model.Comment) (string, error) {
-	return strconv.Itoa(obj.ID), nil
+	return relay.ToGlobalID(relay.TypeComment, obj.ID), nil
 }
 
 // PostID is the resolver for the postId field.
 func (r *commentResolver) PostID(ctx context.Context, obj *model.Comment) (string, error) {
-	return strconv.Itoa(obj.PostID), nil
+	return relay.ToGlobalID(relay.TypePost, obj.PostID), nil
 }
 
 // ParentCommentID is the resolver for the parentCommentId field.
@@ -28,8 +36,8 @@ func (r *commentResolver) ParentCommentID(ctx context.Context, obj *model.Commen
 	if obj.ParentCommentID == nil {
 		return nil, nil
 	}
-	strParentCommentID := strconv.Itoa(*obj.ParentCommentID)
-	return &strParentCommentID, nil
+	parentCommentID := relay.ToGlobalID(relay.TypeComment, *obj.ParentCommentID)
+	return &parentCommentID, nil
 }
 
 // CreatedAt is the resolver for the createdAt field.
@@ -37,6 +45,122 @@ func (r *commentResolver) CreatedAt(ctx context.Context, obj *model.Comment) (st
 	return obj.CreatedAt.Format(time.RFC3339), nil
 }
 
+// EditedAt is the resolver for the editedAt field.
+func (r *commentResolver) EditedAt(ctx context.Context, obj *model.Comment) (*string, error) {
+	if obj.EditedAt == nil {
+		return nil, nil
+	}
+	editedAt := obj.EditedAt.Format(time.RFC3339)
+	return &editedAt, nil
+}
+
+// Revisions is the resolver for the revisions field.
+func (r *commentResolver) Revisions(ctx context.Context, obj *model.Comment) ([]*model.CommentRevision, error) {
+	revisions, err := r.loaders(ctx).Revisions.Load(ctx, obj.ID)
+	if err != nil {
+		return nil, fmt.Errorf("не удалось получить историю изменений комментария: %w", err)
+	}
+
+	result := make([]*model.CommentRevision, len(revisions))
+	for i := range revisions {
+		result[i] = &revisions[i]
+	}
+	return result, nil
+}
+
+// Children is the resolver for the children field.
+func (r *commentResolver) Children(ctx context.Context, obj *model.Comment) ([]*model.Comment, error) {
+	// комментарий пришел из commentTree - дерево уже собрано
+	if obj.Children != nil {
+		return obj.Children, nil
+	}
+
+	// ответы для всех комментариев списка загружаются одной пачкой
+	replies, err := r.loaders(ctx).Replies.Load(ctx, obj.ID)
+	if err != nil {
+		return nil, fmt.Errorf("не удалось получить ответы на комментарий: %w", err)
+	}
+	result := make([]*model.Comment, len(replies))
+	for i := range replies {
+		result[i] = &replies[i]
+	}
+	return result, nil
+}
+
+// ReplyCount is the resolver for the replyCount field.
+func (r *commentResolver) ReplyCount(ctx context.Context, obj *model.Comment) (int, error) {
+	// в дереве количество ответов уже посчитано, в том числе для свернутых узлов
+	if obj.Children != nil {
+		return obj.ChildCount, nil
+	}
+
+	counts, err := r.loaders(ctx).CommentCounts.Load(ctx, obj.ID)
+	if err != nil {
+		return 0, fmt.Errorf("не удалось получить количество ответов: %w", err)
+	}
+	return counts.ReplyCount, nil
+}
+
+// DescendantCount is the resolver for the descendantCount field.
+func (r *commentResolver) DescendantCount(ctx context.Context, obj *model.Comment) (int, error) {
+	counts, err := r.loaders(ctx).CommentCounts.Load(ctx, obj.ID)
+	if err != nil {
+		return 0, fmt.Errorf("не удалось получить количество ответов: %w", err)
+	}
+	return counts.DescendantCount, nil
+}
+
+// Post is the resolver for the post field.
+func (r *commentResolver) Post(ctx context.Context, obj *model.Comment) (*model.Post, error) {
+	// для списка комментариев (ответы, событие подписки) посты грузятся одной пачкой
+	post, err := r.loaders(ctx).Posts.Load(ctx, obj.PostID)
+	if err != nil {
+		return nil, fmt.Errorf("не удалось получить пост комментария: %w", err)
+	}
+	if post == nil {
+		return nil, service.ErrPostNotFound
+	}
+	return post, nil
+}
+
+// Parent is the resolver for the parent field.
+func (r *commentResolver) Parent(ctx context.Context, obj *model.Comment) (*model.Comment, error) {
+	if obj.ParentCommentID == nil {
+		return nil, nil
+	}
+	parent, err := r.loaders(ctx).Comments.Load(ctx, *obj.ParentCommentID)
+	if err != nil {
+		return nil, fmt.Errorf("не удалось получить родительский комментарий: %w", err)
+	}
+	if parent == nil {
+		return nil, service.ErrCommentNotFound
+	}
+	return parent, nil
+}
+
+// Ancestors is the resolver for the ancestors field.
+func (r *commentResolver) Ancestors(ctx context.Context, obj *model.Comment) ([]*model.Comment, error) {
+	// у корневого комментария предков нет, в хранилище не иду
+	if obj.ParentCommentID == nil {
+		return []*model.Comment{}, nil
+	}
+
+	ancestors, err := r.CommentService.GetCommentAncestors(ctx, obj.ID)
+	if err != nil {
+		return nil, fmt.Errorf("не удалось получить цепочку родительских комментариев: %w", err)
+	}
+	result := make([]*model.Comment, len(ancestors))
+	for i := range ancestors {
+		result[i] = &ancestors[i]
+	}
+	return result, nil
+}
+
+// CreatedAt is the resolver for the createdAt field.
+func (r *commentRevisionResolver) CreatedAt(ctx context.Context, obj *model.CommentRevision) (string, error) {
+	return obj.CreatedAt.Format(time.RFC3339), nil
+}
+
 // CreatePost is the resolver for the createPost field.
 func (r *mutationResolver) CreatePost(ctx context.Context, title string, content string, author string, areCommentsAllowed bool) (*model.Post, error) {
 	post := &model.Post{
@@ -46,22 +170,69 @@ func (r *mutationResolver) CreatePost(ctx context.Context, title string, content
 		AreCommentsAllowed: areCommentsAllowed,
 	}
 	if err := r.PostService.CreatePost(ctx, post); err != nil {
-		return nil, fmt.Errorf("не удалось создать пост: %v", err)
+		return nil, fmt.Errorf("не удалось создать пост: %w", err)
+	}
+	return post, nil
+}
+
+// UpdatePost is the resolver for the updatePost field.
+func (r *mutationResolver) UpdatePost(ctx context.Context, id string, title *string, content *string) (*model.Post, error) {
+	idInt, err := parseID("id", id, relay.TypePost)
+	if err != nil {
+		return nil, err
+	}
+	post, err := r.PostService.GetPostByID(ctx, idInt)
+	if err != nil {
+		return nil, fmt.Errorf("не удалось получить пост: %w", err)
+	}
+	if title != nil {
+		post.Title = *title
+	}
+	if content != nil {
+		post.Content = *content
+	}
+	if err = r.PostService.UpdatePost(ctx, post); err != nil {
+		return nil, fmt.Errorf("не удалось обновить пост: %w", err)
+	}
+	return post, nil
+}
+
+// DeletePost is the resolver for the deletePost field.
+func (r *mutationResolver) DeletePost(ctx context.Context, id string) (bool, error) {
+	idInt, err := parseID("id", id, relay.TypePost)
+	if err != nil {
+		return false, err
+	}
+	if err = r.PostService.DeletePost(ctx, idInt); err != nil {
+		return false, fmt.Errorf("не удалось удалить пост: %w", err)
+	}
+	return true, nil
+}
+
+// SetCommentsAllowed is the resolver for the setCommentsAllowed field.
+func (r *mutationResolver) SetCommentsAllowed(ctx context.Context, postID string, allowed bool) (*model.Post, error) {
+	idInt, err := parseID("postId", postID, relay.TypePost)
+	if err != nil {
+		return nil, err
+	}
+	post, err := r.PostService.SetCommentsAllowed(ctx, idInt, allowed)
+	if err != nil {
+		return nil, fmt.Errorf("не удалось изменить разрешение на комментирование: %w", err)
 	}
 	return post, nil
 }
 
 // CreateComment is the resolver for the createComment field.
 func (r *mutationResolver) CreateComment(ctx context.Context, postID string, parentID *string, author string, content string) (*model.Comment, error) {
-	intID, err := strconv.Atoi(postID)
+	intID, err := parseID("postId", postID, relay.TypePost)
 	if err != nil {
-		return nil, fmt.Errorf("не удалось преобразовать id поста в int: %v", err)
+		return nil, err
 	}
 	var parent *int
 	if parentID != nil {
-		intParentID, err := strconv.Atoi(*parentID)
+		intParentID, err := parseID("parentId", *parentID, relay.TypeComment)
 		if err != nil {
-			return nil, fmt.Errorf("не удалось преобразовать parentID: %v", err)
+			return nil, err
 		}
 		parent = &intParentID
 	}
@@ -75,15 +246,43 @@ func (r *mutationResolver) CreateComment(ctx context.Context, postID string, par
 
 	err = r.CommentService.CreateComment(ctx, comment)
 	if err != nil {
-		return nil, fmt.Errorf("не удалось создать комментарий: %v", err)
+		return nil, fmt.Errorf("не удалось создать комментарий: %w", err)
+	}
+
+	return comment, nil
+}
+
+// EditComment is the resolver for the editComment field.
+func (r *mutationResolver) EditComment(ctx context.Context, id string, content string) (*model.Comment, error) {
+	intID, err := parseID("id", id, relay.TypeComment)
+	if err != nil {
+		return nil, err
 	}
 
+	comment, err := r.CommentService.EditComment(ctx, intID, content)
+	if err != nil {
+		return nil, fmt.Errorf("не удалось отредактировать комментарий: %w", err)
+	}
+	return comment, nil
+}
+
+// DeleteComment is the resolver for the deleteComment field.
+func (r *mutationResolver) DeleteComment(ctx context.Context, id string) (*model.Comment, error) {
+	intID, err := parseID("id", id, relay.TypeComment)
+	if err != nil {
+		return nil, err
+	}
+
+	comment, err := r.CommentService.DeleteComment(ctx, intID)
+	if err != nil {
+		return nil, fmt.Errorf("не удалось удалить комментарий: %w", err)
+	}
 	return comment, nil
 }
 
 // ID is the resolver for the id field.
 func (r *postResolver) ID(ctx context.Context, obj *model.Post) (string, error) {
-	return strconv.Itoa(obj.ID), nil
+	return relay.ToGlobalID(relay.TypePost, obj.ID), nil
 }
 
 // CreatedAt is the resolver for the createdAt field.
@@ -102,25 +301,50 @@ func (r *postResolver) Comments(ctx context.Context, obj *model.Post, limit *int
 		offset = &defaultOffset
 	}
 
-	comments, totalPages, err := r.CommentService.GetCommentsByPost(ctx, obj.ID, *limit, *offset)
+	// при запросе комментариев для списка постов загрузчик соберет их в один запрос к хранилищу
+	page, err := r.loaders(ctx).RootComments.Load(ctx, dataloader.CommentsKey{PostID: obj.ID, Limit: *limit, Offset: *offset})
 	if err != nil {
-		return nil, fmt.Errorf("не удалось получить комментарии для поста: %v", err)
+		return nil, fmt.Errorf("не удалось получить комментарии для поста: %w", err)
+	}
+	if page.Comments == nil {
+		page.Comments = []*model.Comment{}
 	}
-	resultComments := make([]*model.Comment, len(comments))
+	return &page, nil
+}
+
+// CommentsConnection is the resolver for the commentsConnection field.
+func (r *postResolver) CommentsConnection(ctx context.Context, obj *model.Post, first *int, after *string, last *int, before *string) (*generated.CommentConnection, error) {
+	page, err := pagination.NewPage(first, after, last, before)
+	if err != nil {
+		return nil, paginationError(err)
+	}
+
+	comments, hasMore, err := r.CommentService.GetCommentsPage(ctx, obj.ID, page)
+	if err != nil {
+		return nil, fmt.Errorf("не удалось получить комментарии для поста: %w", err)
+	}
+
+	edges := make([]*generated.CommentEdge, len(comments))
+	cursors := make([]string, len(comments))
 	for i := range comments {
-		resultComments[i] = &comments[i]
+		cursors[i] = pagination.NewCursor(comments[i].CreatedAt, comments[i].ID).Encode()
+		edges[i] = &generated.CommentEdge{Cursor: cursors[i], Node: &comments[i]}
 	}
-	return &model.PaginatedComments{
-		Comments:   resultComments,
-		TotalPages: totalPages,
+	return &generated.CommentConnection{
+		Edges:    edges,
+		PageInfo: newPageInfo(page, hasMore, cursors),
 	}, nil
 }
 
 // Posts is the resolver for the posts field.
-func (r *queryResolver) Posts(ctx context.Context) ([]*model.Post, error) {
-	posts, err := r.PostService.GetAllPosts(ctx)
+func (r *queryResolver) Posts(ctx context.Context, orderBy *model.PostOrder, createdAfter *time.Time) ([]*model.Post, error) {
+	filter := model.PostsFilter{Order: model.PostOrderCreatedAt, CreatedAfter: createdAfter}
+	if orderBy != nil {
+		filter.Order = *orderBy
+	}
+	posts, err := r.PostService.GetAllPosts(ctx, filter)
 	if err != nil {
-		return nil, fmt.Errorf("не удалось получить список постов: %v", err)
+		return nil, fmt.Errorf("не удалось получить список постов: %w", err)
 	}
 	result := make([]*model.Post, len(posts))
 	for i := range posts {
@@ -129,28 +353,138 @@ func (r *queryResolver) Posts(ctx context.Context) ([]*model.Post, error) {
 	return result, nil
 }
 
+// PostsConnection is the resolver for the postsConnection field.
+func (r *queryResolver) PostsConnection(ctx context.Context, first *int, after *string, last *int, before *string) (*generated.PostConnection, error) {
+	page, err := pagination.NewPage(first, after, last, before)
+	if err != nil {
+		return nil, paginationError(err)
+	}
+
+	posts, hasMore, err := r.PostService.GetPostsPage(ctx, page)
+	if err != nil {
+		return nil, fmt.Errorf("не удалось получить список постов: %w", err)
+	}
+
+	edges := make([]*generated.PostEdge, len(posts))
+	cursors := make([]string, len(posts))
+	for i := range posts {
+		cursors[i] = pagination.NewCursor(posts[i].CreatedAt, posts[i].ID).Encode()
+		edges[i] = &generated.PostEdge{Cursor: cursors[i], Node: &posts[i]}
+	}
+	return &generated.PostConnection{
+		Edges:    edges,
+		PageInfo: newPageInfo(page, hasMore, cursors),
+	}, nil
+}
+
 // Post is the resolver for the post field.
 func (r *queryResolver) Post(ctx context.Context, id string) (*model.Post, error) {
-	idInt, err := strconv.Atoi(id)
+	idInt, err := parseID("id", id, relay.TypePost)
 	if err != nil {
-		return nil, fmt.Errorf("не удалось преобразовать id поста в int: %v", err)
+		return nil, err
 	}
 	post, err := r.PostService.GetPostByID(ctx, idInt)
 	if err != nil {
-		return nil, fmt.Errorf("не удалось получить пост: %v", err)
+		return nil, fmt.Errorf("не удалось получить пост: %w", err)
 	}
 	return post, nil
 }
 
+// Comment is the resolver for the comment field.
+func (r *queryResolver) Comment(ctx context.Context, id string) (*model.Comment, error) {
+	intID, err := parseID("id", id, relay.TypeComment)
+	if err != nil {
+		return nil, err
+	}
+	comment, err := r.CommentService.GetCommentByID(ctx, intID)
+	if err != nil {
+		return nil, fmt.Errorf("не удалось получить комментарий: %w", err)
+	}
+	return comment, nil
+}
+
+// Node is the resolver for the node field.
+func (r *queryResolver) Node(ctx context.Context, id string) (model.Node, error) {
+	typeName, intID, err := relay.FromGlobalID(id)
+	if err != nil {
+		return nil, service.NewValidationError("id", service.KeyInvalidID, err.Error())
+	}
+
+	// через загрузчики, чтобы nodes(ids) читал объекты одного типа одним запросом
+	switch typeName {
+	case relay.TypePost:
+		post, err := r.loaders(ctx).Posts.Load(ctx, intID)
+		if err != nil {
+			return nil, fmt.Errorf("не удалось получить пост: %w", err)
+		}
+		if post == nil {
+			return nil, service.ErrPostNotFound
+		}
+		return post, nil
+	case relay.TypeComment:
+		comment, err := r.loaders(ctx).Comments.Load(ctx, intID)
+		if err != nil {
+			return nil, fmt.Errorf("не удалось получить комментарий: %w", err)
+		}
+		if comment == nil {
+			return nil, service.ErrCommentNotFound
+		}
+		return comment, nil
+	}
+	return nil, service.NewValidationError("id", service.KeyInvalidID, fmt.Sprintf("неизвестный тип объекта: %s", typeName))
+}
+
+// Nodes is the resolver for the nodes field.
+func (r *queryResolver) Nodes(ctx context.Context, ids []string) ([]model.Node, error) {
+	if len(ids) > maxNodes {
+		return nil, service.NewValidationError("ids", service.KeyTooManyIDs, fmt.Sprintf("можно запросить не больше %d объектов", maxNodes))
+	}
+
+	// id запрашиваются одновременно, чтобы общие для них загрузчики собрали их в пачки.
+	// ошибка по одному id не должна ломать весь список: на его месте будет null,
+	// а id попадает в extensions ошибки, чтобы не потеряться при переводе сообщения
+	ctx = dataloader.NewContext(ctx, r.loaders(ctx))
+	nodes := make([]model.Node, len(ids))
+	var wg sync.WaitGroup
+	for i, id := range ids {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			node, err := r.Node(ctx, id)
+			if err != nil {
+				gqlErr := gqlerror.WrapPath(append(graphql.GetPath(ctx), ast.PathIndex(i)), err)
+				gqlErr.Extensions = map[string]any{"id": id}
+				graphql.AddError(ctx, gqlErr)
+				return
+			}
+			nodes[i] = node
+		}()
+	}
+	wg.Wait()
+	return nodes, nil
+}
+
 // Replies is the resolver for the replies field.
-func (r *queryResolver) Replies(ctx context.Context, id string) ([]*model.Comment, error) {
-	intID, err := strconv.Atoi(id)
+func (r *queryResolver) Replies(ctx context.Context, id string, maxDepth *int, first *int, after *string) ([]*model.Comment, error) {
+	intID, err := parseID("id", id, relay.TypeComment)
 	if err != nil {
 		return nil, err
 	}
-	replies, err := r.CommentService.GetReplies(ctx, intID)
+	var afterID *int
+	if after != nil {
+		parsed, err := parseID("after", *after, relay.TypeComment)
+		if err != nil {
+			return nil, err
+		}
+		afterID = &parsed
+	}
+	page, err := pagination.NewRepliesPage(maxDepth, first, afterID)
 	if err != nil {
-		return nil, fmt.Errorf("не удалось получить ответы на комментарий: %v", err)
+		return nil, paginationError(err)
+	}
+	replies, err := r.CommentService.GetReplies(ctx, intID, page)
+	if err != nil {
+		return nil, fmt.Errorf("не удалось получить ответы на комментарий: %w", err)
 	}
 
 	result := make([]*model.Comment, len(replies))
@@ -160,15 +494,46 @@ func (r *queryResolver) Replies(ctx context.Context, id string) ([]*model.Commen
 	return result, nil
 }
 
+// CommentTree is the resolver for the commentTree field.
+func (r *queryResolver) CommentTree(ctx context.Context, rootID string, maxDepth *int) (*model.Comment, error) {
+	intID, err := parseID("rootId", rootID, relay.TypeComment)
+	if err != nil {
+		return nil, err
+	}
+	depth := 0
+	if maxDepth != nil {
+		if *maxDepth < 1 {
+			return nil, paginationError(pagination.ErrMaxDepth)
+		}
+		depth = *maxDepth
+	}
+
+	tree, err := r.CommentService.GetCommentTree(ctx, intID, depth)
+	if err != nil {
+		return nil, fmt.Errorf("не удалось получить дерево комментариев: %w", err)
+	}
+	return tree, nil
+}
+
 // NewComment is the resolver for the newComment field.
-func (r *subscriptionResolver) NewComment(ctx context.Context, postID int) (<-chan *model.Comment, error) {
-	ch := r.SubscriptionService.Subscribe(postID)
+func (r *subscriptionResolver) NewComment(ctx context.Context, postID string) (<-chan *model.Comment, error) {
+	intID, err := parseID("postID", postID, relay.TypePost)
+	if err != nil {
+		return nil, err
+	}
+	// ctx отменяется при разрыве websocket или завершении подписки клиентом - тогда подписчик удаляется
+	ch := r.SubscriptionService.Subscribe(ctx, intID)
 	return ch, nil
 }
 
 // Comment returns generated.CommentResolver implementation.
 func (r *Resolver) Comment() generated.CommentResolver { return &commentResolver{r} }
 
+// CommentRevision returns generated.CommentRevisionResolver implementation.
+func (r *Resolver) CommentRevision() generated.CommentRevisionResolver {
+	return &commentRevisionResolver{r}
+}
+
 // Mutation returns generated.MutationResolver implementation.
 func (r *Resolver) Mutation() generated.MutationResolver { return &mutationResolver{r} }
 
@@ -182,28 +547,8 @@ func (r *Resolver) Query() generated.QueryResolver { return &queryResolver{r} }
 func (r *Resolver) Subscription() generated.SubscriptionResolver { return &subscriptionResolver{r} }
 
 type commentResolver struct{ *Resolver }
+type commentRevisionResolver struct{ *Resolver }
 type mutationResolver struct{ *Resolver }
 type postResolver struct{ *Resolver }
 type queryResolver struct{ *Resolver }
 type subscriptionResolver struct{ *Resolver }
-
-// !!! WARNING !!!
-// The code below was going to be deleted when updating resolvers. It has been copied here so you have
-// one last chance to move it out of harms way if you want. There are two reasons this happens:
-//  - When renaming or deleting a resolver the old code will be put in here. You can safely delete
-//    it when you're done.
-//  - You have helper methods in this file. Move them out to keep these resolver files clean.
-/*
-	func (r *commentResolver) Replies(ctx context.Context, obj *model.Comment) ([]*model.Comment, error) {
-	replies, err := r.CommentService.GetReplies(ctx, obj.ID)
-	if err != nil {
-		return nil, fmt.Errorf("не удалось получить ответы на комментарий: %v", err)
-	}
-
-	result := make([]*model.Comment, len(replies))
-	for i := range replies {
-		result[i] = &replies[i]
-	}
-	return result, nil
-}
-*/
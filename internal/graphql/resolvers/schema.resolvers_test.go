@@ -1,13 +1,21 @@
 package resolvers
 
 import (
+	"OzonTestTask/internal/graphql/dataloader"
+	"OzonTestTask/internal/graphql/relay"
 	"OzonTestTask/internal/mocks"
 	"OzonTestTask/internal/model"
+	"OzonTestTask/internal/pagination"
+	"OzonTestTask/internal/service"
 	"OzonTestTask/internal/subscription"
 	"context"
+	"github.com/99designs/gqlgen/graphql"
+	"github.com/stretchr/testify/assert"
 	"github.com/stretchr/testify/mock"
 	"github.com/stretchr/testify/require"
+	"sync"
 	"testing"
+	"time"
 )
 
 var ctx = context.Background()
@@ -31,6 +39,49 @@ func TestCreatePost(t *testing.T) {
 	mockPostService.AssertExpectations(t)
 }
 
+func TestUpdatePost(t *testing.T) {
+	mockPostService := new(mocks.PostService)
+	r := &Resolver{PostService: mockPostService}
+	mutation := &mutationResolver{r}
+	mockPostService.On("GetPostByID", mock.Anything, 1).
+		Return(&model.Post{ID: 1, Title: "Старый", Content: "Текст", Author: "Даша"}, nil)
+	mockPostService.
+		On("UpdatePost", mock.Anything, mock.AnythingOfType("*model.Post")).
+		Return(nil)
+
+	title := "Новый"
+	post, err := mutation.UpdatePost(ctx, "1", &title, nil)
+	require.NoError(t, err)
+	require.Equal(t, title, post.Title)
+	require.Equal(t, "Текст", post.Content)
+	mockPostService.AssertExpectations(t)
+}
+
+func TestDeletePost(t *testing.T) {
+	mockPostService := new(mocks.PostService)
+	r := &Resolver{PostService: mockPostService}
+	mutation := &mutationResolver{r}
+	mockPostService.On("DeletePost", mock.Anything, 1).Return(nil)
+
+	deleted, err := mutation.DeletePost(ctx, "1")
+	require.NoError(t, err)
+	require.True(t, deleted)
+	mockPostService.AssertExpectations(t)
+}
+
+func TestSetCommentsAllowed(t *testing.T) {
+	mockPostService := new(mocks.PostService)
+	r := &Resolver{PostService: mockPostService}
+	mutation := &mutationResolver{r}
+	mockPostService.On("SetCommentsAllowed", mock.Anything, 1, false).
+		Return(&model.Post{ID: 1, AreCommentsAllowed: false}, nil)
+
+	post, err := mutation.SetCommentsAllowed(ctx, "1", false)
+	require.NoError(t, err)
+	require.False(t, post.AreCommentsAllowed)
+	mockPostService.AssertExpectations(t)
+}
+
 func TestCreateComment(t *testing.T) {
 	mockCommentService := new(mocks.CommentService)
 	r := &Resolver{CommentService: mockCommentService}
@@ -51,24 +102,111 @@ func TestCreateComment(t *testing.T) {
 	mockCommentService.AssertExpectations(t)
 }
 
+func TestEditComment(t *testing.T) {
+	mockCommentService := new(mocks.CommentService)
+	r := &Resolver{CommentService: mockCommentService}
+	mutation := &mutationResolver{r}
+	editedAt := time.Now()
+	mockCommentService.On("EditComment", mock.Anything, 1, "Исправлено").
+		Return(&model.Comment{ID: 1, Content: "Исправлено", EditedAt: &editedAt}, nil)
+
+	comment, err := mutation.EditComment(ctx, "1", "Исправлено")
+	require.NoError(t, err)
+	require.Equal(t, "Исправлено", comment.Content)
+
+	formatted, err := (&commentResolver{r}).EditedAt(ctx, comment)
+	require.NoError(t, err)
+	require.Equal(t, editedAt.Format(time.RFC3339), *formatted)
+	mockCommentService.AssertExpectations(t)
+}
+
+func TestDeleteComment(t *testing.T) {
+	mockCommentService := new(mocks.CommentService)
+	r := &Resolver{CommentService: mockCommentService}
+	mutation := &mutationResolver{r}
+	mockCommentService.On("DeleteComment", mock.Anything, 1).
+		Return(&model.Comment{ID: 1, Path: "1", Deleted: true}, nil)
+
+	comment, err := mutation.DeleteComment(ctx, "1")
+	require.NoError(t, err)
+	require.True(t, comment.Deleted)
+	require.Equal(t, "1", comment.Path)
+	mockCommentService.AssertExpectations(t)
+}
+
 func TestGetPosts(t *testing.T) {
 	mockPostService := new(mocks.PostService)
 	r := &Resolver{PostService: mockPostService}
 	query := &queryResolver{r}
 	mockPostService.
-		On("GetAllPosts", mock.Anything).
+		On("GetAllPosts", mock.Anything, model.PostsFilter{Order: model.PostOrderCreatedAt}).
 		Return([]model.Post{
 			{ID: 1},
 			{ID: 2},
 		}, nil)
 
-	posts, err := query.Posts(ctx)
+	posts, err := query.Posts(ctx, nil, nil)
 	require.NoError(t, err)
 	require.Equal(t, 1, posts[0].ID)
 	require.Equal(t, 2, posts[1].ID)
 	mockPostService.AssertExpectations(t)
 }
 
+func TestGetPosts_Filter(t *testing.T) {
+	mockPostService := new(mocks.PostService)
+	r := &Resolver{PostService: mockPostService}
+	query := &queryResolver{r}
+
+	createdAfter := time.Date(2024, 1, 2, 15, 4, 5, 0, time.UTC)
+	order := model.PostOrderLastActivity
+	mockPostService.
+		On("GetAllPosts", mock.Anything, model.PostsFilter{Order: order, CreatedAfter: &createdAfter}).
+		Return([]model.Post{{ID: 3}}, nil)
+
+	posts, err := query.Posts(ctx, &order, &createdAfter)
+	require.NoError(t, err)
+	require.Len(t, posts, 1)
+	mockPostService.AssertExpectations(t)
+}
+
+func TestPostsConnection(t *testing.T) {
+	mockPostService := new(mocks.PostService)
+	r := &Resolver{PostService: mockPostService}
+	query := &queryResolver{r}
+	now := time.Now().UTC()
+	mockPostService.
+		On("GetPostsPage", mock.Anything, mock.AnythingOfType("pagination.Page")).
+		Return([]model.Post{
+			{ID: 3, CreatedAt: now},
+			{ID: 2, CreatedAt: now.Add(-time.Minute)},
+		}, true, nil)
+
+	first := 2
+	conn, err := query.PostsConnection(ctx, &first, nil, nil, nil)
+	require.NoError(t, err)
+	require.Len(t, conn.Edges, 2)
+	require.Equal(t, 3, conn.Edges[0].Node.ID)
+	require.True(t, conn.PageInfo.HasNextPage)
+	require.False(t, conn.PageInfo.HasPreviousPage)
+	require.Equal(t, conn.Edges[1].Cursor, *conn.PageInfo.EndCursor)
+
+	cursor, err := pagination.DecodeCursor(*conn.PageInfo.EndCursor)
+	require.NoError(t, err)
+	require.Equal(t, 2, cursor.ID)
+	mockPostService.AssertExpectations(t)
+}
+
+func TestPostsConnection_FirstAndLast(t *testing.T) {
+	mockPostService := new(mocks.PostService)
+	r := &Resolver{PostService: mockPostService}
+	query := &queryResolver{r}
+
+	first, last := 2, 2
+	_, err := query.PostsConnection(ctx, &first, nil, &last, nil)
+	require.Error(t, err)
+	mockPostService.AssertNotCalled(t, "GetPostsPage", mock.Anything, mock.Anything)
+}
+
 func TestGetPost(t *testing.T) {
 	mockPostService := new(mocks.PostService)
 	mockCommentService := new(mocks.CommentService)
@@ -90,17 +228,180 @@ func TestGetPost(t *testing.T) {
 	mockPostService.AssertExpectations(t)
 }
 
+func TestNode(t *testing.T) {
+	mockPostService := new(mocks.PostService)
+	mockCommentService := new(mocks.CommentService)
+	r := &Resolver{
+		PostService:    mockPostService,
+		CommentService: mockCommentService,
+	}
+	query := &queryResolver{r}
+
+	// у поста и комментария одинаковый id в хранилище, но разные глобальные id
+	mockPostService.On("GetPostsByIDs", mock.Anything, []int{1}).Return(map[int]model.Post{1: {ID: 1, Title: "Пост"}}, nil)
+	mockCommentService.On("GetCommentsByIDs", mock.Anything, []int{1}).Return(map[int]model.Comment{1: {ID: 1, PostID: 1}}, nil)
+	mockPostService.On("GetPostByID", mock.Anything, 1).Return(&model.Post{ID: 1, Title: "Пост"}, nil)
+
+	postID, err := (&postResolver{r}).ID(ctx, &model.Post{ID: 1})
+	require.NoError(t, err)
+	commentID, err := (&commentResolver{r}).ID(ctx, &model.Comment{ID: 1})
+	require.NoError(t, err)
+	require.NotEqual(t, postID, commentID)
+
+	node, err := query.Node(ctx, postID)
+	require.NoError(t, err)
+	require.IsType(t, &model.Post{}, node)
+
+	node, err = query.Node(ctx, commentID)
+	require.NoError(t, err)
+	require.IsType(t, &model.Comment{}, node)
+
+	// числовой id не указывает тип объекта, поэтому в node не принимается
+	_, err = query.Node(ctx, "1")
+	require.Error(t, err)
+
+	// аргументы с id поста принимают глобальный id, но не id комментария
+	_, err = query.Post(ctx, postID)
+	require.NoError(t, err)
+	_, err = query.Post(ctx, commentID)
+	require.Error(t, err)
+
+	mockPostService.AssertExpectations(t)
+	mockCommentService.AssertExpectations(t)
+}
+
+func TestNodes(t *testing.T) {
+	mockPostService := new(mocks.PostService)
+	r := &Resolver{PostService: mockPostService, CommentService: new(mocks.CommentService)}
+	query := &queryResolver{r}
+
+	// оба поста загружаются одним запросом, ненайденный получает null и ошибку
+	mockPostService.On("GetPostsByIDs", mock.Anything, mock.MatchedBy(func(ids []int) bool {
+		return len(ids) == 2
+	})).Return(map[int]model.Post{1: {ID: 1}}, nil).Once()
+
+	reqCtx := graphql.WithResponseContext(ctx, graphql.DefaultErrorPresenter, graphql.DefaultRecover)
+	nodes, err := query.Nodes(reqCtx, []string{relay.ToGlobalID(relay.TypePost, 1), relay.ToGlobalID(relay.TypePost, 2)})
+	require.NoError(t, err)
+	require.Len(t, nodes, 2)
+	require.NotNil(t, nodes[0])
+	require.Nil(t, nodes[1])
+	errs := graphql.GetErrors(reqCtx)
+	require.Len(t, errs, 1)
+	require.ErrorIs(t, errs[0], service.ErrPostNotFound)
+	assert.Equal(t, relay.ToGlobalID(relay.TypePost, 2), errs[0].Extensions["id"])
+	mockPostService.AssertExpectations(t)
+
+	tooMany := make([]string, maxNodes+1)
+	for i := range tooMany {
+		tooMany[i] = relay.ToGlobalID(relay.TypePost, i+1)
+	}
+	_, err = query.Nodes(reqCtx, tooMany)
+	require.ErrorIs(t, err, service.ErrValidation)
+}
+
+func TestGetComment(t *testing.T) {
+	mockPostService := new(mocks.PostService)
+	mockCommentService := new(mocks.CommentService)
+	r := &Resolver{
+		PostService:    mockPostService,
+		CommentService: mockCommentService,
+	}
+	query := &queryResolver{r}
+	commentRes := &commentResolver{r}
+
+	rootID := 1
+	mockCommentService.On("GetCommentByID", mock.Anything, 3).
+		Return(&model.Comment{ID: 3, PostID: 7, ParentCommentID: &rootID, Path: "1.2.3", Content: "Глубокий ответ"}, nil)
+	mockCommentService.On("GetCommentsByIDs", mock.Anything, []int{1}).
+		Return(map[int]model.Comment{1: {ID: 1, PostID: 7, Path: "1", Author: "Анна"}}, nil)
+	mockPostService.On("GetPostsByIDs", mock.Anything, []int{7}).
+		Return(map[int]model.Post{7: {ID: 7, Title: "Пост"}}, nil)
+
+	comment, err := query.Comment(ctx, relay.ToGlobalID(relay.TypeComment, 3))
+	require.NoError(t, err)
+	require.Equal(t, "Глубокий ответ", comment.Content)
+
+	post, err := commentRes.Post(ctx, comment)
+	require.NoError(t, err)
+	require.Equal(t, "Пост", post.Title)
+
+	parent, err := commentRes.Parent(ctx, comment)
+	require.NoError(t, err)
+	require.Equal(t, "Анна", parent.Author)
+
+	// у корневого комментария родителя нет
+	parent, err = commentRes.Parent(ctx, parent)
+	require.NoError(t, err)
+	require.Nil(t, parent)
+
+	_, err = query.Comment(ctx, relay.ToGlobalID(relay.TypePost, 3))
+	require.Error(t, err)
+
+	mockPostService.AssertExpectations(t)
+	mockCommentService.AssertExpectations(t)
+}
+
+func TestCommentPostAndParent_Batched(t *testing.T) {
+	mockPostService := new(mocks.PostService)
+	mockCommentService := new(mocks.CommentService)
+	r := &Resolver{
+		PostService:    mockPostService,
+		CommentService: mockCommentService,
+	}
+	commentRes := &commentResolver{r}
+	loadersCtx := dataloader.NewContext(ctx, dataloader.NewLoaders(mockPostService, mockCommentService))
+
+	mockPostService.On("GetPostsByIDs", mock.Anything, mock.AnythingOfType("[]int")).
+		Return(map[int]model.Post{1: {ID: 1, Title: "Первый"}, 2: {ID: 2, Title: "Второй"}}, nil)
+	mockCommentService.On("GetCommentsByIDs", mock.Anything, mock.AnythingOfType("[]int")).
+		Return(map[int]model.Comment{10: {ID: 10, Author: "Анна"}}, nil)
+
+	// ответы на разные комментарии из разных постов, как в списке или событиях подписки
+	parentID, missingID := 10, 11
+	comments := []*model.Comment{
+		{ID: 20, PostID: 1, ParentCommentID: &parentID},
+		{ID: 21, PostID: 2, ParentCommentID: &parentID},
+		{ID: 22, PostID: 1, ParentCommentID: &missingID},
+	}
+
+	var wg sync.WaitGroup
+	posts := make([]*model.Post, len(comments))
+	parents := make([]*model.Comment, len(comments))
+	parentErrs := make([]error, len(comments))
+	for i, c := range comments {
+		wg.Add(2)
+		go func() {
+			defer wg.Done()
+			posts[i], _ = commentRes.Post(loadersCtx, c)
+		}()
+		go func() {
+			defer wg.Done()
+			parents[i], parentErrs[i] = commentRes.Parent(loadersCtx, c)
+		}()
+	}
+	wg.Wait()
+
+	require.Equal(t, "Первый", posts[0].Title)
+	require.Equal(t, "Второй", posts[1].Title)
+	require.Equal(t, "Анна", parents[0].Author)
+	require.Equal(t, "Анна", parents[1].Author)
+	require.ErrorIs(t, parentErrs[2], service.ErrNotFound)
+
+	mockPostService.AssertNumberOfCalls(t, "GetPostsByIDs", 1)
+	mockCommentService.AssertNumberOfCalls(t, "GetCommentsByIDs", 1)
+}
+
 func TestGetComments(t *testing.T) {
 	mockCommentService := new(mocks.CommentService)
 	r := &Resolver{CommentService: mockCommentService}
 	query := &postResolver{Resolver: r}
 
 	mockCommentService.
-		On("GetCommentsByPost", mock.Anything, mock.AnythingOfType("int"),
-			mock.AnythingOfType("int"), mock.AnythingOfType("int")).
-		Return([]model.Comment{
-			{ID: 1, Author: "Иван"},
-		}, 1, nil)
+		On("GetCommentsByPosts", mock.Anything, []int{1}, 5, 0).
+		Return(map[int][]model.Comment{
+			1: {{ID: 1, Author: "Иван"}},
+		}, map[int]int{1: 1}, nil)
 
 	post := model.Post{
 		ID:                 1,
@@ -122,17 +423,82 @@ func TestGetComments(t *testing.T) {
 	mockCommentService.AssertExpectations(t)
 }
 
+func TestGetComments_BatchedForPostsList(t *testing.T) {
+	mockCommentService := new(mocks.CommentService)
+	r := &Resolver{CommentService: mockCommentService}
+	query := &postResolver{Resolver: r}
+
+	// комментарии для всех постов списка должны прийти одним вызовом
+	mockCommentService.
+		On("GetCommentsByPosts", mock.Anything, mock.AnythingOfType("[]int"), 5, 0).
+		Return(func(ctx context.Context, postIDs []int, limit, offset int) (map[int][]model.Comment, map[int]int, error) {
+			comments := make(map[int][]model.Comment, len(postIDs))
+			totalPages := make(map[int]int, len(postIDs))
+			for _, id := range postIDs {
+				comments[id] = []model.Comment{{ID: id * 10, PostID: id}}
+				totalPages[id] = 1
+			}
+			return comments, totalPages, nil
+		}).Once()
+
+	loadersCtx := dataloader.NewContext(ctx, dataloader.NewLoaders(nil, mockCommentService))
+	limit, offset := 5, 0
+
+	var wg sync.WaitGroup
+	results := make([]*model.PaginatedComments, 3)
+	for i := range results {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			page, err := query.Comments(loadersCtx, &model.Post{ID: i + 1}, &limit, &offset)
+			assert.NoError(t, err)
+			results[i] = page
+		}()
+	}
+	wg.Wait()
+
+	for i, page := range results {
+		require.Len(t, page.Comments, 1)
+		require.Equal(t, (i+1)*10, page.Comments[0].ID)
+	}
+	mockCommentService.AssertNumberOfCalls(t, "GetCommentsByPosts", 1)
+}
+
+func TestCommentsConnection(t *testing.T) {
+	mockCommentService := new(mocks.CommentService)
+	r := &Resolver{CommentService: mockCommentService}
+	query := &postResolver{Resolver: r}
+	now := time.Now().UTC()
+
+	mockCommentService.
+		On("GetCommentsPage", mock.Anything, 1, mock.AnythingOfType("pagination.Page")).
+		Return([]model.Comment{
+			{ID: 4, Author: "Иван", CreatedAt: now},
+		}, true, nil)
+
+	last := 1
+	conn, err := query.CommentsConnection(ctx, &model.Post{ID: 1}, nil, nil, &last, nil)
+	require.NoError(t, err)
+	require.Len(t, conn.Edges, 1)
+	require.Equal(t, "Иван", conn.Edges[0].Node.Author)
+	require.False(t, conn.PageInfo.HasNextPage)
+	require.True(t, conn.PageInfo.HasPreviousPage)
+	require.Equal(t, conn.Edges[0].Cursor, *conn.PageInfo.StartCursor)
+
+	mockCommentService.AssertExpectations(t)
+}
+
 func TestGetReplies(t *testing.T) {
 	mockCommentService := new(mocks.CommentService)
 	r := &Resolver{CommentService: mockCommentService}
 	query := &queryResolver{Resolver: r}
 
-	mockCommentService.On("GetReplies", mock.Anything, mock.AnythingOfType("int")).
+	mockCommentService.On("GetReplies", mock.Anything, mock.AnythingOfType("int"), pagination.RepliesPage{}).
 		Return([]model.Comment{
 			{ID: 1, Author: "Сергей", Content: "Ответ 1"},
 			{ID: 2, Author: "Александр", Content: "Ответ 2"}}, nil)
 
-	replies, err := query.Replies(ctx, "1")
+	replies, err := query.Replies(ctx, "1", nil, nil, nil)
 	require.NoError(t, err)
 	require.Len(t, replies, 2)
 	require.Equal(t, "Ответ 1", replies[0].Content)
@@ -140,18 +506,126 @@ func TestGetReplies(t *testing.T) {
 	mockCommentService.AssertExpectations(t)
 }
 
+func TestCommentTree(t *testing.T) {
+	mockCommentService := new(mocks.CommentService)
+	r := &Resolver{CommentService: mockCommentService}
+	query := &queryResolver{Resolver: r}
+	comment := &commentResolver{Resolver: r}
+
+	leaf := &model.Comment{ID: 2, Children: []*model.Comment{}, ChildCount: 3}
+	mockCommentService.On("GetCommentTree", mock.Anything, 1, 1).
+		Return(&model.Comment{ID: 1, Children: []*model.Comment{leaf}, ChildCount: 1}, nil)
+
+	depth := 1
+	tree, err := query.CommentTree(ctx, "1", &depth)
+	require.NoError(t, err)
+
+	children, err := comment.Children(ctx, tree)
+	require.NoError(t, err)
+	require.Len(t, children, 1)
+
+	// у свернутого узла дети не подгружаются, но видно их количество
+	collapsed, err := comment.Children(ctx, children[0])
+	require.NoError(t, err)
+	require.Empty(t, collapsed)
+	count, err := comment.ReplyCount(ctx, children[0])
+	require.NoError(t, err)
+	require.Equal(t, 3, count)
+
+	mockCommentService.AssertExpectations(t)
+}
+
+func TestCommentChildren_Batched(t *testing.T) {
+	mockCommentService := new(mocks.CommentService)
+	r := &Resolver{CommentService: mockCommentService}
+	comment := &commentResolver{Resolver: r}
+	loadersCtx := dataloader.NewContext(ctx, dataloader.NewLoaders(nil, mockCommentService))
+
+	parent := 1
+	mockCommentService.On("GetRepliesByParents", mock.Anything, mock.MatchedBy(func(ids []int) bool {
+		return len(ids) == 2
+	})).Return(map[int][]model.Comment{
+		1: {{ID: 3, ParentCommentID: &parent}},
+		2: {},
+	}, nil).Once()
+
+	// ответы двух комментариев из одного списка уходят в хранилище одной пачкой
+	results := make([][]*model.Comment, 2)
+	var wg sync.WaitGroup
+	for i := range results {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			children, err := comment.Children(loadersCtx, &model.Comment{ID: i + 1})
+			assert.NoError(t, err)
+			results[i] = children
+		}()
+	}
+	wg.Wait()
+
+	require.Len(t, results[0], 1)
+	assert.Equal(t, 3, results[0][0].ID)
+	assert.Empty(t, results[1])
+	mockCommentService.AssertExpectations(t)
+}
+
+func TestCommentAncestors(t *testing.T) {
+	mockCommentService := new(mocks.CommentService)
+	r := &Resolver{CommentService: mockCommentService}
+	commentRes := &commentResolver{r}
+
+	rootID, parentID := 1, 2
+	mockCommentService.On("GetCommentAncestors", mock.Anything, 3).
+		Return([]model.Comment{{ID: rootID, Path: "1"}, {ID: parentID, ParentCommentID: &rootID, Path: "1.2"}}, nil)
+
+	ancestors, err := commentRes.Ancestors(ctx, &model.Comment{ID: 3, ParentCommentID: &parentID, Path: "1.2.3"})
+	require.NoError(t, err)
+	require.Len(t, ancestors, 2)
+	require.Equal(t, rootID, ancestors[0].ID)
+	require.Equal(t, parentID, ancestors[1].ID)
+
+	// для корневого комментария хранилище не вызывается
+	ancestors, err = commentRes.Ancestors(ctx, &model.Comment{ID: rootID, Path: "1"})
+	require.NoError(t, err)
+	require.Empty(t, ancestors)
+
+	mockCommentService.AssertNumberOfCalls(t, "GetCommentAncestors", 1)
+}
+
+func TestCommentCounts(t *testing.T) {
+	mockCommentService := new(mocks.CommentService)
+	r := &Resolver{CommentService: mockCommentService}
+	comment := &commentResolver{Resolver: r}
+
+	mockCommentService.On("GetCommentCounts", mock.Anything, []int{7}).
+		Return(map[int]model.CommentCounts{7: {CommentID: 7, ReplyCount: 14, DescendantCount: 40}}, nil)
+
+	replyCount, err := comment.ReplyCount(ctx, &model.Comment{ID: 7})
+	require.NoError(t, err)
+	require.Equal(t, 14, replyCount)
+
+	descendantCount, err := comment.DescendantCount(ctx, &model.Comment{ID: 7})
+	require.NoError(t, err)
+	require.Equal(t, 40, descendantCount)
+
+	mockCommentService.AssertExpectations(t)
+}
+
 func TestSubscription(t *testing.T) {
 	mockSubscription := new(mocks.Subscription)
 	r := &Resolver{SubscriptionService: mockSubscription}
 	sub := &subscriptionResolver{Resolver: r}
 
 	ch := make(subscription.SubscriptionChan, 1)
-	mockSubscription.On("Subscribe", mock.AnythingOfType("int")).
+	mockSubscription.On("Subscribe", mock.Anything, 1).
 		Return(ch)
 
-	result, err := sub.NewComment(ctx, 1)
+	result, err := sub.NewComment(ctx, relay.ToGlobalID(relay.TypePost, 1))
 	require.NoError(t, err)
 
+	_, err = sub.NewComment(ctx, relay.ToGlobalID(relay.TypeComment, 1))
+	require.ErrorIs(t, err, service.ErrValidation, "id комментария вместо id поста")
+
 	comment := &model.Comment{ID: 1, Author: "Мария", Content: "Привет"}
 	ch <- comment
 
@@ -0,0 +1,39 @@
+package scalars
+
+import (
+	"OzonTestTask/internal/service"
+	"fmt"
+	"io"
+	"strconv"
+	"time"
+
+	"github.com/99designs/gqlgen/graphql"
+)
+
+// MarshalDateTime Время всегда отдаю в UTC в формате RFC3339 с дробной частью секунд,
+// чтобы у клиентов не было расхождений из-за часовых поясов и округления
+func MarshalDateTime(t time.Time) graphql.Marshaler {
+	if t.IsZero() {
+		return graphql.Null
+	}
+	return graphql.WriterFunc(func(w io.Writer) {
+		io.WriteString(w, strconv.Quote(t.UTC().Format(time.RFC3339Nano)))
+	})
+}
+
+// UnmarshalDateTime Принимаю только строку RFC3339 с явным часовым поясом (Z или смещение).
+// Числа, даты без времени и время без пояса отклоняются, а не угадываются.
+// Ошибка - ошибка валидации, чтобы клиент получил код VALIDATION_FAILED и перевод
+func UnmarshalDateTime(v any) (time.Time, error) {
+	s, ok := v.(string)
+	if !ok {
+		return time.Time{}, service.NewValidationError("", service.KeyInvalidDateTime,
+			fmt.Sprintf("DateTime должен быть строкой в формате RFC3339, получено %T", v))
+	}
+	t, err := time.Parse(time.RFC3339Nano, s)
+	if err != nil {
+		return time.Time{}, service.NewValidationError("", service.KeyInvalidDateTime,
+			fmt.Sprintf("DateTime должен быть в формате RFC3339, например 2024-01-02T15:04:05Z: %q", s))
+	}
+	return t.UTC(), nil
+}
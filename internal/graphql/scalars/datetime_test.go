@@ -0,0 +1,48 @@
+package scalars
+
+import (
+	"bytes"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"testing"
+	"time"
+)
+
+func TestMarshalDateTime(t *testing.T) {
+	moscow := time.FixedZone("MSK", 3*60*60)
+	value := time.Date(2024, 1, 2, 18, 4, 5, 123000000, moscow)
+
+	var buf bytes.Buffer
+	MarshalDateTime(value).MarshalGQL(&buf)
+	assert.Equal(t, `"2024-01-02T15:04:05.123Z"`, buf.String(), "время отдается в UTC")
+
+	buf.Reset()
+	MarshalDateTime(time.Time{}).MarshalGQL(&buf)
+	assert.Equal(t, "null", buf.String())
+}
+
+func TestUnmarshalDateTime(t *testing.T) {
+	value, err := UnmarshalDateTime("2024-01-02T18:04:05+03:00")
+	require.NoError(t, err)
+	assert.Equal(t, time.Date(2024, 1, 2, 15, 4, 5, 0, time.UTC), value)
+	assert.Equal(t, time.UTC, value.Location())
+
+	value, err = UnmarshalDateTime("2024-01-02T15:04:05.5Z")
+	require.NoError(t, err)
+	assert.Equal(t, 500*time.Millisecond, time.Duration(value.Nanosecond()))
+}
+
+func TestUnmarshalDateTime_Strict(t *testing.T) {
+	for _, v := range []any{
+		"2024-01-02",
+		"2024-01-02T15:04:05",
+		"2024-01-02 15:04:05Z",
+		"02.01.2024",
+		"",
+		1704207845,
+		nil,
+	} {
+		_, err := UnmarshalDateTime(v)
+		assert.Errorf(t, err, "значение %v должно быть отклонено", v)
+	}
+}
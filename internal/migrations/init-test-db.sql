@@ -6,7 +6,9 @@ CREATE TABLE IF NOT EXISTS posts (
                                      content TEXT NOT NULL,
                                      author TEXT NOT NULL,
                                      are_comments_allowed BOOLEAN DEFAULT TRUE,
-                                     created_at TIMESTAMP NOT NULL DEFAULT NOW()
+                                     created_at TIMESTAMP NOT NULL DEFAULT NOW(),
+                                     comment_count INT NOT NULL DEFAULT 0,
+                                     last_comment_at TIMESTAMP
 );
 
 CREATE TABLE IF NOT EXISTS comments (
@@ -16,9 +18,67 @@ CREATE TABLE IF NOT EXISTS comments (
                                         content TEXT NOT NULL CHECK (length(content) <= 2000),
                                         parent_comment_id INT REFERENCES comments(id) ON DELETE CASCADE,
                                         path ltree NOT NULL,
-                                        created_at TIMESTAMP NOT NULL DEFAULT NOW()
+                                        created_at TIMESTAMP NOT NULL DEFAULT NOW(),
+                                        edited_at TIMESTAMP,
+                                        deleted BOOLEAN NOT NULL DEFAULT FALSE
 );
 
+-- базы, созданные до появления правок и мягкого удаления комментариев
+ALTER TABLE comments ADD COLUMN IF NOT EXISTS edited_at TIMESTAMP;
+ALTER TABLE comments ADD COLUMN IF NOT EXISTS deleted BOOLEAN NOT NULL DEFAULT FALSE;
+
+-- базы, созданные до появления счетчиков: колонки добавляются один раз и заполняются по уже существующим комментариям
+DO $$
+BEGIN
+    IF NOT EXISTS (SELECT 1 FROM information_schema.columns WHERE table_schema = current_schema() AND table_name = 'posts' AND column_name = 'comment_count') THEN
+        ALTER TABLE posts ADD COLUMN IF NOT EXISTS comment_count INT NOT NULL DEFAULT 0;
+        ALTER TABLE posts ADD COLUMN IF NOT EXISTS last_comment_at TIMESTAMP;
+        UPDATE posts SET comment_count = (SELECT count(*) FROM comments WHERE comments.post_id = posts.id),
+                         last_comment_at = (SELECT max(created_at) FROM comments WHERE comments.post_id = posts.id);
+    END IF;
+END $$;
+
+CREATE TABLE IF NOT EXISTS comment_revisions (
+                                        id SERIAL PRIMARY KEY,
+                                        comment_id INT NOT NULL REFERENCES comments(id) ON DELETE CASCADE,
+                                        content TEXT NOT NULL,
+                                        created_at TIMESTAMP NOT NULL
+);
+
+-- уведомления о новых комментариях пишутся в одной транзакции с комментарием и отправляются relay.
+-- payload - снимок комментария на момент создания, отправленные уведомления relay удаляет через час
+CREATE TABLE IF NOT EXISTS comment_outbox (
+                                        id SERIAL PRIMARY KEY,
+                                        comment_id INT NOT NULL REFERENCES comments(id) ON DELETE CASCADE,
+                                        payload JSONB NOT NULL,
+                                        created_at TIMESTAMP NOT NULL DEFAULT NOW(),
+                                        delivered_at TIMESTAMP
+);
+
+-- outbox, созданный до появления снимков: уже записанные уведомления получают снимок текущего состояния комментария
+ALTER TABLE comment_outbox ADD COLUMN IF NOT EXISTS payload JSONB;
+UPDATE comment_outbox o
+SET payload = jsonb_build_object(
+        'id', c.id,
+        'post_id', c.post_id,
+        'parent_comment_id', c.parent_comment_id,
+        'path', c.path::text,
+        'author', c.author,
+        'content', c.content,
+        'created_at', c.created_at AT TIME ZONE 'UTC',
+        'edited_at', c.edited_at AT TIME ZONE 'UTC',
+        'deleted', c.deleted)
+FROM comments c
+WHERE c.id = o.comment_id AND o.payload IS NULL;
+ALTER TABLE comment_outbox ALTER COLUMN payload SET NOT NULL;
+
 CREATE INDEX IF NOT EXISTS idx_comments_path ON comments USING GIST (path);
 CREATE INDEX IF NOT EXISTS idx_post_id ON comments(post_id);
-CREATE INDEX IF NOT EXISTS idx_post_created_at ON posts(created_at)
+CREATE INDEX IF NOT EXISTS idx_parent_comment_id ON comments(parent_comment_id);
+CREATE INDEX IF NOT EXISTS idx_root_comments_created_at ON comments(post_id, created_at, id) WHERE parent_comment_id IS NULL;
+CREATE INDEX IF NOT EXISTS idx_comment_revisions_comment_id ON comment_revisions(comment_id);
+CREATE INDEX IF NOT EXISTS idx_comment_outbox_pending ON comment_outbox(id) WHERE delivered_at IS NULL;
+CREATE INDEX IF NOT EXISTS idx_comment_outbox_comment_id ON comment_outbox(comment_id);
+CREATE INDEX IF NOT EXISTS idx_comment_outbox_delivered_at ON comment_outbox(delivered_at) WHERE delivered_at IS NOT NULL;
+CREATE INDEX IF NOT EXISTS idx_post_created_at ON posts(created_at);
+CREATE INDEX IF NOT EXISTS idx_post_last_activity ON posts((COALESCE(last_comment_at, created_at)) DESC, id DESC)
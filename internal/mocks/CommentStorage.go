@@ -4,6 +4,7 @@ package mocks
 
 import (
 	model "OzonTestTask/internal/model"
+	pagination "OzonTestTask/internal/pagination"
 	context "context"
 
 	mock "github.com/stretchr/testify/mock"
@@ -32,6 +33,246 @@ func (_m *CommentStorage) CreateComment(ctx context.Context, comment *model.Comm
 	return r0
 }
 
+// DeleteComment provides a mock function with given fields: ctx, id
+func (_m *CommentStorage) DeleteComment(ctx context.Context, id int) (*model.Comment, error) {
+	ret := _m.Called(ctx, id)
+
+	if len(ret) == 0 {
+		panic("no return value specified for DeleteComment")
+	}
+
+	var r0 *model.Comment
+	var r1 error
+	if rf, ok := ret.Get(0).(func(context.Context, int) (*model.Comment, error)); ok {
+		return rf(ctx, id)
+	}
+	if rf, ok := ret.Get(0).(func(context.Context, int) *model.Comment); ok {
+		r0 = rf(ctx, id)
+	} else {
+		if ret.Get(0) != nil {
+			r0 = ret.Get(0).(*model.Comment)
+		}
+	}
+
+	if rf, ok := ret.Get(1).(func(context.Context, int) error); ok {
+		r1 = rf(ctx, id)
+	} else {
+		r1 = ret.Error(1)
+	}
+
+	return r0, r1
+}
+
+// EditComment provides a mock function with given fields: ctx, id, content
+func (_m *CommentStorage) EditComment(ctx context.Context, id int, content string) (*model.Comment, error) {
+	ret := _m.Called(ctx, id, content)
+
+	if len(ret) == 0 {
+		panic("no return value specified for EditComment")
+	}
+
+	var r0 *model.Comment
+	var r1 error
+	if rf, ok := ret.Get(0).(func(context.Context, int, string) (*model.Comment, error)); ok {
+		return rf(ctx, id, content)
+	}
+	if rf, ok := ret.Get(0).(func(context.Context, int, string) *model.Comment); ok {
+		r0 = rf(ctx, id, content)
+	} else {
+		if ret.Get(0) != nil {
+			r0 = ret.Get(0).(*model.Comment)
+		}
+	}
+
+	if rf, ok := ret.Get(1).(func(context.Context, int, string) error); ok {
+		r1 = rf(ctx, id, content)
+	} else {
+		r1 = ret.Error(1)
+	}
+
+	return r0, r1
+}
+
+// GetCommentAncestors provides a mock function with given fields: ctx, id
+func (_m *CommentStorage) GetCommentAncestors(ctx context.Context, id int) ([]model.Comment, error) {
+	ret := _m.Called(ctx, id)
+
+	if len(ret) == 0 {
+		panic("no return value specified for GetCommentAncestors")
+	}
+
+	var r0 []model.Comment
+	var r1 error
+	if rf, ok := ret.Get(0).(func(context.Context, int) ([]model.Comment, error)); ok {
+		return rf(ctx, id)
+	}
+	if rf, ok := ret.Get(0).(func(context.Context, int) []model.Comment); ok {
+		r0 = rf(ctx, id)
+	} else {
+		if ret.Get(0) != nil {
+			r0 = ret.Get(0).([]model.Comment)
+		}
+	}
+
+	if rf, ok := ret.Get(1).(func(context.Context, int) error); ok {
+		r1 = rf(ctx, id)
+	} else {
+		r1 = ret.Error(1)
+	}
+
+	return r0, r1
+}
+
+// GetCommentByID provides a mock function with given fields: ctx, id
+func (_m *CommentStorage) GetCommentByID(ctx context.Context, id int) (*model.Comment, error) {
+	ret := _m.Called(ctx, id)
+
+	if len(ret) == 0 {
+		panic("no return value specified for GetCommentByID")
+	}
+
+	var r0 *model.Comment
+	var r1 error
+	if rf, ok := ret.Get(0).(func(context.Context, int) (*model.Comment, error)); ok {
+		return rf(ctx, id)
+	}
+	if rf, ok := ret.Get(0).(func(context.Context, int) *model.Comment); ok {
+		r0 = rf(ctx, id)
+	} else {
+		if ret.Get(0) != nil {
+			r0 = ret.Get(0).(*model.Comment)
+		}
+	}
+
+	if rf, ok := ret.Get(1).(func(context.Context, int) error); ok {
+		r1 = rf(ctx, id)
+	} else {
+		r1 = ret.Error(1)
+	}
+
+	return r0, r1
+}
+
+// GetCommentCounts provides a mock function with given fields: ctx, ids
+func (_m *CommentStorage) GetCommentCounts(ctx context.Context, ids []int) (map[int]model.CommentCounts, error) {
+	ret := _m.Called(ctx, ids)
+
+	if len(ret) == 0 {
+		panic("no return value specified for GetCommentCounts")
+	}
+
+	var r0 map[int]model.CommentCounts
+	var r1 error
+	if rf, ok := ret.Get(0).(func(context.Context, []int) (map[int]model.CommentCounts, error)); ok {
+		return rf(ctx, ids)
+	}
+	if rf, ok := ret.Get(0).(func(context.Context, []int) map[int]model.CommentCounts); ok {
+		r0 = rf(ctx, ids)
+	} else {
+		if ret.Get(0) != nil {
+			r0 = ret.Get(0).(map[int]model.CommentCounts)
+		}
+	}
+
+	if rf, ok := ret.Get(1).(func(context.Context, []int) error); ok {
+		r1 = rf(ctx, ids)
+	} else {
+		r1 = ret.Error(1)
+	}
+
+	return r0, r1
+}
+
+// GetCommentRevisions provides a mock function with given fields: ctx, commentID
+func (_m *CommentStorage) GetCommentRevisions(ctx context.Context, commentID int) ([]model.CommentRevision, error) {
+	ret := _m.Called(ctx, commentID)
+
+	if len(ret) == 0 {
+		panic("no return value specified for GetCommentRevisions")
+	}
+
+	var r0 []model.CommentRevision
+	var r1 error
+	if rf, ok := ret.Get(0).(func(context.Context, int) ([]model.CommentRevision, error)); ok {
+		return rf(ctx, commentID)
+	}
+	if rf, ok := ret.Get(0).(func(context.Context, int) []model.CommentRevision); ok {
+		r0 = rf(ctx, commentID)
+	} else {
+		if ret.Get(0) != nil {
+			r0 = ret.Get(0).([]model.CommentRevision)
+		}
+	}
+
+	if rf, ok := ret.Get(1).(func(context.Context, int) error); ok {
+		r1 = rf(ctx, commentID)
+	} else {
+		r1 = ret.Error(1)
+	}
+
+	return r0, r1
+}
+
+// GetCommentSubtree provides a mock function with given fields: ctx, rootID, maxDepth
+func (_m *CommentStorage) GetCommentSubtree(ctx context.Context, rootID int, maxDepth int) ([]model.Comment, error) {
+	ret := _m.Called(ctx, rootID, maxDepth)
+
+	if len(ret) == 0 {
+		panic("no return value specified for GetCommentSubtree")
+	}
+
+	var r0 []model.Comment
+	var r1 error
+	if rf, ok := ret.Get(0).(func(context.Context, int, int) ([]model.Comment, error)); ok {
+		return rf(ctx, rootID, maxDepth)
+	}
+	if rf, ok := ret.Get(0).(func(context.Context, int, int) []model.Comment); ok {
+		r0 = rf(ctx, rootID, maxDepth)
+	} else {
+		if ret.Get(0) != nil {
+			r0 = ret.Get(0).([]model.Comment)
+		}
+	}
+
+	if rf, ok := ret.Get(1).(func(context.Context, int, int) error); ok {
+		r1 = rf(ctx, rootID, maxDepth)
+	} else {
+		r1 = ret.Error(1)
+	}
+
+	return r0, r1
+}
+
+// GetCommentsByIDs provides a mock function with given fields: ctx, ids
+func (_m *CommentStorage) GetCommentsByIDs(ctx context.Context, ids []int) (map[int]model.Comment, error) {
+	ret := _m.Called(ctx, ids)
+
+	if len(ret) == 0 {
+		panic("no return value specified for GetCommentsByIDs")
+	}
+
+	var r0 map[int]model.Comment
+	var r1 error
+	if rf, ok := ret.Get(0).(func(context.Context, []int) (map[int]model.Comment, error)); ok {
+		return rf(ctx, ids)
+	}
+	if rf, ok := ret.Get(0).(func(context.Context, []int) map[int]model.Comment); ok {
+		r0 = rf(ctx, ids)
+	} else {
+		if ret.Get(0) != nil {
+			r0 = ret.Get(0).(map[int]model.Comment)
+		}
+	}
+
+	if rf, ok := ret.Get(1).(func(context.Context, []int) error); ok {
+		r1 = rf(ctx, ids)
+	} else {
+		r1 = ret.Error(1)
+	}
+
+	return r0, r1
+}
+
 // GetCommentsByPost provides a mock function with given fields: ctx, postID, limit, offset
 func (_m *CommentStorage) GetCommentsByPost(ctx context.Context, postID int, limit int, offset int) ([]model.Comment, int, error) {
 	ret := _m.Called(ctx, postID, limit, offset)
@@ -69,6 +310,82 @@ func (_m *CommentStorage) GetCommentsByPost(ctx context.Context, postID int, lim
 	return r0, r1, r2
 }
 
+// GetCommentsByPosts provides a mock function with given fields: ctx, postIDs, limit, offset
+func (_m *CommentStorage) GetCommentsByPosts(ctx context.Context, postIDs []int, limit int, offset int) (map[int][]model.Comment, map[int]int, error) {
+	ret := _m.Called(ctx, postIDs, limit, offset)
+
+	if len(ret) == 0 {
+		panic("no return value specified for GetCommentsByPosts")
+	}
+
+	var r0 map[int][]model.Comment
+	var r1 map[int]int
+	var r2 error
+	if rf, ok := ret.Get(0).(func(context.Context, []int, int, int) (map[int][]model.Comment, map[int]int, error)); ok {
+		return rf(ctx, postIDs, limit, offset)
+	}
+	if rf, ok := ret.Get(0).(func(context.Context, []int, int, int) map[int][]model.Comment); ok {
+		r0 = rf(ctx, postIDs, limit, offset)
+	} else {
+		if ret.Get(0) != nil {
+			r0 = ret.Get(0).(map[int][]model.Comment)
+		}
+	}
+
+	if rf, ok := ret.Get(1).(func(context.Context, []int, int, int) map[int]int); ok {
+		r1 = rf(ctx, postIDs, limit, offset)
+	} else {
+		if ret.Get(1) != nil {
+			r1 = ret.Get(1).(map[int]int)
+		}
+	}
+
+	if rf, ok := ret.Get(2).(func(context.Context, []int, int, int) error); ok {
+		r2 = rf(ctx, postIDs, limit, offset)
+	} else {
+		r2 = ret.Error(2)
+	}
+
+	return r0, r1, r2
+}
+
+// GetCommentsPage provides a mock function with given fields: ctx, postID, page
+func (_m *CommentStorage) GetCommentsPage(ctx context.Context, postID int, page pagination.Page) ([]model.Comment, bool, error) {
+	ret := _m.Called(ctx, postID, page)
+
+	if len(ret) == 0 {
+		panic("no return value specified for GetCommentsPage")
+	}
+
+	var r0 []model.Comment
+	var r1 bool
+	var r2 error
+	if rf, ok := ret.Get(0).(func(context.Context, int, pagination.Page) ([]model.Comment, bool, error)); ok {
+		return rf(ctx, postID, page)
+	}
+	if rf, ok := ret.Get(0).(func(context.Context, int, pagination.Page) []model.Comment); ok {
+		r0 = rf(ctx, postID, page)
+	} else {
+		if ret.Get(0) != nil {
+			r0 = ret.Get(0).([]model.Comment)
+		}
+	}
+
+	if rf, ok := ret.Get(1).(func(context.Context, int, pagination.Page) bool); ok {
+		r1 = rf(ctx, postID, page)
+	} else {
+		r1 = ret.Get(1).(bool)
+	}
+
+	if rf, ok := ret.Get(2).(func(context.Context, int, pagination.Page) error); ok {
+		r2 = rf(ctx, postID, page)
+	} else {
+		r2 = ret.Error(2)
+	}
+
+	return r0, r1, r2
+}
+
 // GetPostByID provides a mock function with given fields: ctx, id
 func (_m *CommentStorage) GetPostByID(ctx context.Context, id int) (*model.Post, error) {
 	ret := _m.Called(ctx, id)
@@ -99,9 +416,9 @@ func (_m *CommentStorage) GetPostByID(ctx context.Context, id int) (*model.Post,
 	return r0, r1
 }
 
-// GetReplies provides a mock function with given fields: ctx, parentCommentID
-func (_m *CommentStorage) GetReplies(ctx context.Context, parentCommentID int) ([]model.Comment, error) {
-	ret := _m.Called(ctx, parentCommentID)
+// GetReplies provides a mock function with given fields: ctx, parentCommentID, page
+func (_m *CommentStorage) GetReplies(ctx context.Context, parentCommentID int, page pagination.RepliesPage) ([]model.Comment, error) {
+	ret := _m.Called(ctx, parentCommentID, page)
 
 	if len(ret) == 0 {
 		panic("no return value specified for GetReplies")
@@ -109,19 +426,79 @@ func (_m *CommentStorage) GetReplies(ctx context.Context, parentCommentID int) (
 
 	var r0 []model.Comment
 	var r1 error
-	if rf, ok := ret.Get(0).(func(context.Context, int) ([]model.Comment, error)); ok {
-		return rf(ctx, parentCommentID)
+	if rf, ok := ret.Get(0).(func(context.Context, int, pagination.RepliesPage) ([]model.Comment, error)); ok {
+		return rf(ctx, parentCommentID, page)
 	}
-	if rf, ok := ret.Get(0).(func(context.Context, int) []model.Comment); ok {
-		r0 = rf(ctx, parentCommentID)
+	if rf, ok := ret.Get(0).(func(context.Context, int, pagination.RepliesPage) []model.Comment); ok {
+		r0 = rf(ctx, parentCommentID, page)
 	} else {
 		if ret.Get(0) != nil {
 			r0 = ret.Get(0).([]model.Comment)
 		}
 	}
 
-	if rf, ok := ret.Get(1).(func(context.Context, int) error); ok {
-		r1 = rf(ctx, parentCommentID)
+	if rf, ok := ret.Get(1).(func(context.Context, int, pagination.RepliesPage) error); ok {
+		r1 = rf(ctx, parentCommentID, page)
+	} else {
+		r1 = ret.Error(1)
+	}
+
+	return r0, r1
+}
+
+// GetRepliesByParents provides a mock function with given fields: ctx, parentIDs
+func (_m *CommentStorage) GetRepliesByParents(ctx context.Context, parentIDs []int) (map[int][]model.Comment, error) {
+	ret := _m.Called(ctx, parentIDs)
+
+	if len(ret) == 0 {
+		panic("no return value specified for GetRepliesByParents")
+	}
+
+	var r0 map[int][]model.Comment
+	var r1 error
+	if rf, ok := ret.Get(0).(func(context.Context, []int) (map[int][]model.Comment, error)); ok {
+		return rf(ctx, parentIDs)
+	}
+	if rf, ok := ret.Get(0).(func(context.Context, []int) map[int][]model.Comment); ok {
+		r0 = rf(ctx, parentIDs)
+	} else {
+		if ret.Get(0) != nil {
+			r0 = ret.Get(0).(map[int][]model.Comment)
+		}
+	}
+
+	if rf, ok := ret.Get(1).(func(context.Context, []int) error); ok {
+		r1 = rf(ctx, parentIDs)
+	} else {
+		r1 = ret.Error(1)
+	}
+
+	return r0, r1
+}
+
+// GetRevisionsByComments provides a mock function with given fields: ctx, commentIDs
+func (_m *CommentStorage) GetRevisionsByComments(ctx context.Context, commentIDs []int) (map[int][]model.CommentRevision, error) {
+	ret := _m.Called(ctx, commentIDs)
+
+	if len(ret) == 0 {
+		panic("no return value specified for GetRevisionsByComments")
+	}
+
+	var r0 map[int][]model.CommentRevision
+	var r1 error
+	if rf, ok := ret.Get(0).(func(context.Context, []int) (map[int][]model.CommentRevision, error)); ok {
+		return rf(ctx, commentIDs)
+	}
+	if rf, ok := ret.Get(0).(func(context.Context, []int) map[int][]model.CommentRevision); ok {
+		r0 = rf(ctx, commentIDs)
+	} else {
+		if ret.Get(0) != nil {
+			r0 = ret.Get(0).(map[int][]model.CommentRevision)
+		}
+	}
+
+	if rf, ok := ret.Get(1).(func(context.Context, []int) error); ok {
+		r1 = rf(ctx, commentIDs)
 	} else {
 		r1 = ret.Error(1)
 	}
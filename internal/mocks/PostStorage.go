@@ -4,6 +4,7 @@ package mocks
 
 import (
 	model "OzonTestTask/internal/model"
+	pagination "OzonTestTask/internal/pagination"
 	context "context"
 
 	mock "github.com/stretchr/testify/mock"
@@ -32,9 +33,27 @@ func (_m *PostStorage) CreatePost(ctx context.Context, post *model.Post) error {
 	return r0
 }
 
-// GetAllPosts provides a mock function with given fields: ctx
-func (_m *PostStorage) GetAllPosts(ctx context.Context) ([]model.Post, error) {
-	ret := _m.Called(ctx)
+// DeletePost provides a mock function with given fields: ctx, id
+func (_m *PostStorage) DeletePost(ctx context.Context, id int) error {
+	ret := _m.Called(ctx, id)
+
+	if len(ret) == 0 {
+		panic("no return value specified for DeletePost")
+	}
+
+	var r0 error
+	if rf, ok := ret.Get(0).(func(context.Context, int) error); ok {
+		r0 = rf(ctx, id)
+	} else {
+		r0 = ret.Error(0)
+	}
+
+	return r0
+}
+
+// GetAllPosts provides a mock function with given fields: ctx, filter
+func (_m *PostStorage) GetAllPosts(ctx context.Context, filter model.PostsFilter) ([]model.Post, error) {
+	ret := _m.Called(ctx, filter)
 
 	if len(ret) == 0 {
 		panic("no return value specified for GetAllPosts")
@@ -42,19 +61,19 @@ func (_m *PostStorage) GetAllPosts(ctx context.Context) ([]model.Post, error) {
 
 	var r0 []model.Post
 	var r1 error
-	if rf, ok := ret.Get(0).(func(context.Context) ([]model.Post, error)); ok {
-		return rf(ctx)
+	if rf, ok := ret.Get(0).(func(context.Context, model.PostsFilter) ([]model.Post, error)); ok {
+		return rf(ctx, filter)
 	}
-	if rf, ok := ret.Get(0).(func(context.Context) []model.Post); ok {
-		r0 = rf(ctx)
+	if rf, ok := ret.Get(0).(func(context.Context, model.PostsFilter) []model.Post); ok {
+		r0 = rf(ctx, filter)
 	} else {
 		if ret.Get(0) != nil {
 			r0 = ret.Get(0).([]model.Post)
 		}
 	}
 
-	if rf, ok := ret.Get(1).(func(context.Context) error); ok {
-		r1 = rf(ctx)
+	if rf, ok := ret.Get(1).(func(context.Context, model.PostsFilter) error); ok {
+		r1 = rf(ctx, filter)
 	} else {
 		r1 = ret.Error(1)
 	}
@@ -92,6 +111,121 @@ func (_m *PostStorage) GetPostByID(ctx context.Context, id int) (*model.Post, er
 	return r0, r1
 }
 
+// GetPostsByIDs provides a mock function with given fields: ctx, ids
+func (_m *PostStorage) GetPostsByIDs(ctx context.Context, ids []int) (map[int]model.Post, error) {
+	ret := _m.Called(ctx, ids)
+
+	if len(ret) == 0 {
+		panic("no return value specified for GetPostsByIDs")
+	}
+
+	var r0 map[int]model.Post
+	var r1 error
+	if rf, ok := ret.Get(0).(func(context.Context, []int) (map[int]model.Post, error)); ok {
+		return rf(ctx, ids)
+	}
+	if rf, ok := ret.Get(0).(func(context.Context, []int) map[int]model.Post); ok {
+		r0 = rf(ctx, ids)
+	} else {
+		if ret.Get(0) != nil {
+			r0 = ret.Get(0).(map[int]model.Post)
+		}
+	}
+
+	if rf, ok := ret.Get(1).(func(context.Context, []int) error); ok {
+		r1 = rf(ctx, ids)
+	} else {
+		r1 = ret.Error(1)
+	}
+
+	return r0, r1
+}
+
+// GetPostsPage provides a mock function with given fields: ctx, page
+func (_m *PostStorage) GetPostsPage(ctx context.Context, page pagination.Page) ([]model.Post, bool, error) {
+	ret := _m.Called(ctx, page)
+
+	if len(ret) == 0 {
+		panic("no return value specified for GetPostsPage")
+	}
+
+	var r0 []model.Post
+	var r1 bool
+	var r2 error
+	if rf, ok := ret.Get(0).(func(context.Context, pagination.Page) ([]model.Post, bool, error)); ok {
+		return rf(ctx, page)
+	}
+	if rf, ok := ret.Get(0).(func(context.Context, pagination.Page) []model.Post); ok {
+		r0 = rf(ctx, page)
+	} else {
+		if ret.Get(0) != nil {
+			r0 = ret.Get(0).([]model.Post)
+		}
+	}
+
+	if rf, ok := ret.Get(1).(func(context.Context, pagination.Page) bool); ok {
+		r1 = rf(ctx, page)
+	} else {
+		r1 = ret.Get(1).(bool)
+	}
+
+	if rf, ok := ret.Get(2).(func(context.Context, pagination.Page) error); ok {
+		r2 = rf(ctx, page)
+	} else {
+		r2 = ret.Error(2)
+	}
+
+	return r0, r1, r2
+}
+
+// SetCommentsAllowed provides a mock function with given fields: ctx, id, allowed
+func (_m *PostStorage) SetCommentsAllowed(ctx context.Context, id int, allowed bool) (*model.Post, error) {
+	ret := _m.Called(ctx, id, allowed)
+
+	if len(ret) == 0 {
+		panic("no return value specified for SetCommentsAllowed")
+	}
+
+	var r0 *model.Post
+	var r1 error
+	if rf, ok := ret.Get(0).(func(context.Context, int, bool) (*model.Post, error)); ok {
+		return rf(ctx, id, allowed)
+	}
+	if rf, ok := ret.Get(0).(func(context.Context, int, bool) *model.Post); ok {
+		r0 = rf(ctx, id, allowed)
+	} else {
+		if ret.Get(0) != nil {
+			r0 = ret.Get(0).(*model.Post)
+		}
+	}
+
+	if rf, ok := ret.Get(1).(func(context.Context, int, bool) error); ok {
+		r1 = rf(ctx, id, allowed)
+	} else {
+		r1 = ret.Error(1)
+	}
+
+	return r0, r1
+}
+
+// UpdatePost provides a mock function with given fields: ctx, post
+func (_m *PostStorage) UpdatePost(ctx context.Context, post *model.Post) error {
+	ret := _m.Called(ctx, post)
+
+	if len(ret) == 0 {
+		panic("no return value specified for UpdatePost")
+	}
+
+	var r0 error
+	if rf, ok := ret.Get(0).(func(context.Context, *model.Post) error); ok {
+		r0 = rf(ctx, post)
+	} else {
+		r0 = ret.Error(0)
+	}
+
+	return r0
+}
+
 // NewPostStorage creates a new instance of PostStorage. It also registers a testing interface on the mock and a cleanup function to assert the mocks expectations.
 // The first argument is typically a *testing.T value.
 func NewPostStorage(t interface {
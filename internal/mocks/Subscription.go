@@ -4,6 +4,7 @@ package mocks
 
 import (
 	model "OzonTestTask/internal/model"
+	context "context"
 
 	mock "github.com/stretchr/testify/mock"
 
@@ -51,17 +52,17 @@ func (_m *Subscription) Publish(postID int, comment *model.Comment) error {
 	return r0
 }
 
-// Subscribe provides a mock function with given fields: postID
-func (_m *Subscription) Subscribe(postID int) subscription.SubscriptionChan {
-	ret := _m.Called(postID)
+// Subscribe provides a mock function with given fields: ctx, postID
+func (_m *Subscription) Subscribe(ctx context.Context, postID int) subscription.SubscriptionChan {
+	ret := _m.Called(ctx, postID)
 
 	if len(ret) == 0 {
 		panic("no return value specified for Subscribe")
 	}
 
 	var r0 subscription.SubscriptionChan
-	if rf, ok := ret.Get(0).(func(int) subscription.SubscriptionChan); ok {
-		r0 = rf(postID)
+	if rf, ok := ret.Get(0).(func(context.Context, int) subscription.SubscriptionChan); ok {
+		r0 = rf(ctx, postID)
 	} else {
 		if ret.Get(0) != nil {
 			r0 = ret.Get(0).(subscription.SubscriptionChan)
@@ -3,13 +3,38 @@ package model
 import "time"
 
 type Comment struct {
-	ID              int       `json:"id" db:"id"`
-	PostID          int       `json:"post_id" db:"post_id"`
-	ParentCommentID *int      `json:"parent_comment_id,omitempty" db:"parent_comment_id,omitempty"`
-	Path            string    `json:"path" db:"path"`
-	Author          string    `json:"author" db:"author"`
-	Content         string    `json:"content" db:"content"`
-	CreatedAt       time.Time `json:"created_at" db:"created_at"`
+	ID              int        `json:"id" db:"id"`
+	PostID          int        `json:"post_id" db:"post_id"`
+	ParentCommentID *int       `json:"parent_comment_id,omitempty" db:"parent_comment_id,omitempty"`
+	Path            string     `json:"path" db:"path"`
+	Author          string     `json:"author" db:"author"`
+	Content         string     `json:"content" db:"content"`
+	CreatedAt       time.Time  `json:"created_at" db:"created_at"`
+	EditedAt        *time.Time `json:"edited_at,omitempty" db:"edited_at"`
+	Deleted         bool       `json:"deleted" db:"deleted"`
+
+	// заполняются только при построении дерева комментариев
+	Children   []*Comment `json:"-" db:"-"`
+	ChildCount int        `json:"-" db:"child_count"`
+}
+
+type CommentRevision struct {
+	ID        int       `json:"id" db:"id"`
+	CommentID int       `json:"comment_id" db:"comment_id"`
+	Content   string    `json:"content" db:"content"`
+	CreatedAt time.Time `json:"created_at" db:"created_at"`
+}
+
+// OutboxMessage Уведомление о новом комментарии из outbox со снимком комментария на момент создания
+type OutboxMessage struct {
+	ID      int     `json:"id"`
+	Comment Comment `json:"comment"`
+}
+
+type CommentCounts struct {
+	CommentID       int `json:"comment_id" db:"comment_id"`
+	ReplyCount      int `json:"reply_count" db:"reply_count"`
+	DescendantCount int `json:"descendant_count" db:"descendant_count"`
 }
 
 type PaginatedComments struct {
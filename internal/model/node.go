@@ -0,0 +1,10 @@
+package model
+
+// Node Объект, который клиент может перезапросить по глобальному id через node(id) (интерфейс Node в схеме)
+type Node interface {
+	IsNode()
+}
+
+func (Post) IsNode() {}
+
+func (Comment) IsNode() {}
@@ -3,10 +3,35 @@ package model
 import "time"
 
 type Post struct {
-	ID                 int       `json:"id" db:"id"`
-	Title              string    `json:"title" db:"title"`
-	Content            string    `json:"content" db:"content"`
-	Author             string    `json:"author" db:"author"`
-	AreCommentsAllowed bool      `json:"are_comments_allowed" db:"are_comments_allowed"`
-	CreatedAt          time.Time `json:"created_at" db:"created_at"`
+	ID                 int        `json:"id" db:"id"`
+	Title              string     `json:"title" db:"title"`
+	Content            string     `json:"content" db:"content"`
+	Author             string     `json:"author" db:"author"`
+	AreCommentsAllowed bool       `json:"are_comments_allowed" db:"are_comments_allowed"`
+	CreatedAt          time.Time  `json:"created_at" db:"created_at"`
+	CommentCount       int        `json:"comment_count" db:"comment_count"`
+	LastCommentAt      *time.Time `json:"last_comment_at" db:"last_comment_at"`
 }
+
+// LastActivityAt Время последней активности: последний комментарий, а если их нет - создание поста
+func (p Post) LastActivityAt() time.Time {
+	if p.LastCommentAt != nil {
+		return *p.LastCommentAt
+	}
+	return p.CreatedAt
+}
+
+// PostsFilter Параметры выборки списка постов
+type PostsFilter struct {
+	Order PostOrder
+	// CreatedAfter только посты, созданные строго позже этого времени
+	CreatedAfter *time.Time
+}
+
+// PostOrder Порядок выдачи списка постов
+type PostOrder string
+
+const (
+	PostOrderCreatedAt    PostOrder = "CREATED_AT"
+	PostOrderLastActivity PostOrder = "LAST_ACTIVITY"
+)
@@ -0,0 +1,146 @@
+package pagination
+
+import (
+	"encoding/base64"
+	"errors"
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+)
+
+const (
+	DefaultLimit = 10
+	MaxLimit     = 100
+)
+
+// Cursor Позиция элемента в выдаче, отсортированной по (created_at, id)
+type Cursor struct {
+	CreatedAt time.Time
+	ID        int
+}
+
+// Page Параметры keyset-пагинации в стиле Relay.
+// Backward = true означает запрос last/before - последние Limit элементов перед Before
+type Page struct {
+	After    *Cursor
+	Before   *Cursor
+	Limit    int
+	Backward bool
+}
+
+// RepliesPage Ограничения выборки ветки ответов на комментарий. Нулевые значения - без ограничений
+type RepliesPage struct {
+	MaxDepth int // глубина относительно родителя, 1 - только прямые ответы
+	First    int // сколько прямых ответов (вместе с их ветками) отдать
+	AfterID  int // id последнего полученного прямого ответа
+}
+
+// NewCursor Курсор для элемента с указанными датой создания и id
+func NewCursor(createdAt time.Time, id int) Cursor {
+	return Cursor{CreatedAt: createdAt, ID: id}
+}
+
+// Encode клиенту курсор отдается непрозрачной строкой, чтобы он не завязывался на формат
+func (c Cursor) Encode() string {
+	raw := fmt.Sprintf("%d:%d", c.CreatedAt.UnixNano(), c.ID)
+	return base64.RawURLEncoding.EncodeToString([]byte(raw))
+}
+
+// Less true, если элемент (createdAt, id) находится раньше курсора
+func (c Cursor) Less(createdAt time.Time, id int) bool {
+	if createdAt.Equal(c.CreatedAt) {
+		return id < c.ID
+	}
+	return createdAt.Before(c.CreatedAt)
+}
+
+// Greater true, если элемент (createdAt, id) находится позже курсора
+func (c Cursor) Greater(createdAt time.Time, id int) bool {
+	if createdAt.Equal(c.CreatedAt) {
+		return id > c.ID
+	}
+	return createdAt.After(c.CreatedAt)
+}
+
+func DecodeCursor(s string) (*Cursor, error) {
+	raw, err := base64.RawURLEncoding.DecodeString(s)
+	if err != nil {
+		return nil, ErrInvalidCursor
+	}
+	parts := strings.Split(string(raw), ":")
+	if len(parts) != 2 {
+		return nil, ErrInvalidCursor
+	}
+	nanos, err := strconv.ParseInt(parts[0], 10, 64)
+	if err != nil {
+		return nil, ErrInvalidCursor
+	}
+	id, err := strconv.Atoi(parts[1])
+	if err != nil {
+		return nil, ErrInvalidCursor
+	}
+	return &Cursor{CreatedAt: time.Unix(0, nanos).UTC(), ID: id}, nil
+}
+
+// Ошибки разбора аргументов пагинации
+var (
+	ErrInvalidCursor = errors.New("некорректный курсор")
+	ErrFirstAndLast  = errors.New("нельзя одновременно указывать first и last")
+	ErrPageSize      = fmt.Errorf("размер страницы должен быть от 1 до %d", MaxLimit)
+	ErrMaxDepth      = errors.New("глубина вложенности должна быть не меньше 1")
+)
+
+// NewPage Разбор аргументов first/after/last/before из запроса
+func NewPage(first *int, after *string, last *int, before *string) (Page, error) {
+	if first != nil && last != nil {
+		return Page{}, ErrFirstAndLast
+	}
+
+	page := Page{Limit: DefaultLimit}
+	if first != nil {
+		page.Limit = *first
+	}
+	if last != nil {
+		page.Limit = *last
+		page.Backward = true
+	}
+	if page.Limit < 1 || page.Limit > MaxLimit {
+		return Page{}, ErrPageSize
+	}
+
+	var err error
+	if after != nil {
+		if page.After, err = DecodeCursor(*after); err != nil {
+			return Page{}, err
+		}
+	}
+	if before != nil {
+		if page.Before, err = DecodeCursor(*before); err != nil {
+			return Page{}, err
+		}
+	}
+	return page, nil
+}
+
+// NewRepliesPage Разбор аргументов maxDepth/first/after запроса ветки ответов.
+// after - уже разобранный id комментария: в схеме это глобальный id, его разбирает резолвер
+func NewRepliesPage(maxDepth, first, after *int) (RepliesPage, error) {
+	var page RepliesPage
+	if maxDepth != nil {
+		if *maxDepth < 1 {
+			return RepliesPage{}, ErrMaxDepth
+		}
+		page.MaxDepth = *maxDepth
+	}
+	if first != nil {
+		if *first < 1 || *first > MaxLimit {
+			return RepliesPage{}, ErrPageSize
+		}
+		page.First = *first
+	}
+	if after != nil {
+		page.AfterID = *after
+	}
+	return page, nil
+}
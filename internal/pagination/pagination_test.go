@@ -0,0 +1,87 @@
+package pagination
+
+import (
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"testing"
+	"time"
+)
+
+func TestCursorEncodeDecode(t *testing.T) {
+	cursor := NewCursor(time.Date(2025, 9, 1, 12, 30, 0, 123456000, time.UTC), 42)
+
+	decoded, err := DecodeCursor(cursor.Encode())
+	require.NoError(t, err)
+	assert.True(t, cursor.CreatedAt.Equal(decoded.CreatedAt))
+	assert.Equal(t, cursor.ID, decoded.ID)
+}
+
+func TestDecodeCursor_Invalid(t *testing.T) {
+	for _, s := range []string{"", "не base64", "MTIz", "YWJjOjE"} {
+		_, err := DecodeCursor(s)
+		assert.Error(t, err, "курсор %q должен быть некорректным", s)
+	}
+}
+
+func TestCursorLess(t *testing.T) {
+	now := time.Now().UTC()
+	cursor := NewCursor(now, 5)
+
+	assert.True(t, cursor.Less(now.Add(-time.Second), 10))
+	assert.True(t, cursor.Less(now, 4))
+	assert.False(t, cursor.Less(now, 5))
+	assert.False(t, cursor.Less(now, 6))
+	assert.False(t, cursor.Less(now.Add(time.Second), 1))
+
+	assert.True(t, cursor.Greater(now.Add(time.Second), 1))
+	assert.True(t, cursor.Greater(now, 6))
+	assert.False(t, cursor.Greater(now, 5))
+	assert.False(t, cursor.Greater(now.Add(-time.Second), 10))
+}
+
+func TestNewPage(t *testing.T) {
+	page, err := NewPage(nil, nil, nil, nil)
+	require.NoError(t, err)
+	assert.Equal(t, DefaultLimit, page.Limit)
+	assert.False(t, page.Backward)
+
+	last := 3
+	before := NewCursor(time.Now().UTC(), 7).Encode()
+	page, err = NewPage(nil, nil, &last, &before)
+	require.NoError(t, err)
+	assert.Equal(t, 3, page.Limit)
+	assert.True(t, page.Backward)
+	require.NotNil(t, page.Before)
+	assert.Equal(t, 7, page.Before.ID)
+}
+
+func TestNewPage_Invalid(t *testing.T) {
+	first, last, zero, tooMany := 1, 1, 0, MaxLimit+1
+	wrong := "курсор"
+
+	_, err := NewPage(&first, nil, &last, nil)
+	assert.Error(t, err)
+	_, err = NewPage(&zero, nil, nil, nil)
+	assert.Error(t, err)
+	_, err = NewPage(&tooMany, nil, nil, nil)
+	assert.Error(t, err)
+	_, err = NewPage(&first, &wrong, nil, nil)
+	assert.Error(t, err)
+}
+
+func TestNewRepliesPage(t *testing.T) {
+	page, err := NewRepliesPage(nil, nil, nil)
+	require.NoError(t, err)
+	assert.Equal(t, RepliesPage{}, page)
+
+	depth, first, after := 2, 10, 15
+	page, err = NewRepliesPage(&depth, &first, &after)
+	require.NoError(t, err)
+	assert.Equal(t, RepliesPage{MaxDepth: 2, First: 10, AfterID: 15}, page)
+
+	zero := 0
+	_, err = NewRepliesPage(&zero, nil, nil)
+	assert.Error(t, err)
+	_, err = NewRepliesPage(nil, &zero, nil)
+	assert.Error(t, err)
+}
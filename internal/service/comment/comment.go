@@ -2,6 +2,8 @@ package comment
 
 import (
 	"OzonTestTask/internal/model"
+	"OzonTestTask/internal/pagination"
+	"OzonTestTask/internal/service"
 	"OzonTestTask/internal/storage"
 	"OzonTestTask/internal/subscription"
 	"context"
@@ -23,7 +25,7 @@ func NewCommentService(store storage.CommentStorage, sub subscription.Subscripti
 func (s *CommentService) GetPostByID(ctx context.Context, id int) (*model.Post, error) {
 	post, err := s.store.GetPostByID(ctx, id)
 	if err != nil {
-		return nil, fmt.Errorf("не удалось получить пост: %v", err)
+		return nil, fmt.Errorf("не удалось получить пост: %w", err)
 	}
 	return post, nil
 }
@@ -31,33 +33,29 @@ func (s *CommentService) GetPostByID(ctx context.Context, id int) (*model.Post,
 func (s *CommentService) CreateComment(ctx context.Context, comment *model.Comment) error {
 	post, err := s.store.GetPostByID(ctx, comment.PostID)
 	if err != nil {
-		return fmt.Errorf("пост не найден: %v", err)
+		return fmt.Errorf("не удалось получить пост: %w", err)
 	}
 
 	if !post.AreCommentsAllowed {
-		return fmt.Errorf("этот пост запрещено комментировать")
+		return service.ErrCommentsDisabled
 	}
 
-	if comment.Content == "" {
-		return fmt.Errorf("комментарий не может быть пустым")
+	if err = validateContent(comment.Content); err != nil {
+		return err
 	}
 	if comment.Author == "" {
-		return fmt.Errorf("имя автора не может быть пустым")
-	}
-
-	if len([]rune(comment.Content)) > 2000 {
-		return fmt.Errorf("длина комментария не должна превышать 2000 символов")
+		return service.NewValidationError("author", service.KeyAuthorRequired, "имя автора не может быть пустым")
 	}
 
 	err = s.store.CreateComment(ctx, comment)
 	if err != nil {
-		return fmt.Errorf("не удалось создать комментарий: %v", err)
+		return fmt.Errorf("не удалось создать комментарий: %w", err)
 	}
 
 	if s.sub != nil {
 		err = s.sub.Publish(comment.PostID, comment)
 		if err != nil {
-			return fmt.Errorf("не удалось отправить уведомление о новом комментарии: %v", err)
+			return fmt.Errorf("не удалось отправить уведомление о новом комментарии: %w", err)
 		}
 	}
 
@@ -67,7 +65,7 @@ func (s *CommentService) CreateComment(ctx context.Context, comment *model.Comme
 func (s *CommentService) GetCommentsByPost(ctx context.Context, postID int, limit, offset int) ([]model.Comment, int, error) {
 	comments, totalComments, err := s.store.GetCommentsByPost(ctx, postID, limit, offset)
 	if err != nil {
-		return nil, 0, fmt.Errorf("не удалось получить корневые комментарии: %v", err)
+		return nil, 0, fmt.Errorf("не удалось получить корневые комментарии: %w", err)
 	}
 	// отдаю общее количество страниц - инфа для клиента
 	totalPages := (totalComments + limit - 1) / limit
@@ -75,10 +73,152 @@ func (s *CommentService) GetCommentsByPost(ctx context.Context, postID int, limi
 
 }
 
-func (s *CommentService) GetReplies(ctx context.Context, parentCommentID int) ([]model.Comment, error) {
-	replies, err := s.store.GetReplies(ctx, parentCommentID)
+// GetCommentsByPosts Корневые комментарии сразу для нескольких постов; второе значение - количество страниц по каждому посту
+func (s *CommentService) GetCommentsByPosts(ctx context.Context, postIDs []int, limit, offset int) (map[int][]model.Comment, map[int]int, error) {
+	comments, amounts, err := s.store.GetCommentsByPosts(ctx, postIDs, limit, offset)
 	if err != nil {
-		return nil, fmt.Errorf("не удалось получить вложенные комментарии: %v", err)
+		return nil, nil, fmt.Errorf("не удалось получить корневые комментарии: %w", err)
+	}
+	totalPages := make(map[int]int, len(amounts))
+	for postID, amount := range amounts {
+		totalPages[postID] = (amount + limit - 1) / limit
+	}
+	return comments, totalPages, nil
+}
+
+// GetCommentsPage Страница корневых комментариев по курсору; второе значение - есть ли еще комментарии в направлении пагинации
+func (s *CommentService) GetCommentsPage(ctx context.Context, postID int, page pagination.Page) ([]model.Comment, bool, error) {
+	comments, hasMore, err := s.store.GetCommentsPage(ctx, postID, page)
+	if err != nil {
+		return nil, false, fmt.Errorf("не удалось получить корневые комментарии: %w", err)
+	}
+	return comments, hasMore, nil
+}
+
+func (s *CommentService) GetReplies(ctx context.Context, parentCommentID int, page pagination.RepliesPage) ([]model.Comment, error) {
+	replies, err := s.store.GetReplies(ctx, parentCommentID, page)
+	if err != nil {
+		return nil, fmt.Errorf("не удалось получить вложенные комментарии: %w", err)
 	}
 	return replies, nil
 }
+
+// GetCommentTree Дерево комментариев с корнем rootID глубиной не больше maxDepth (0 - без ограничений).
+// У узлов на границе глубины Children пустой, а ChildCount показывает, сколько ответов свернуто
+func (s *CommentService) GetCommentTree(ctx context.Context, rootID int, maxDepth int) (*model.Comment, error) {
+	subtree, err := s.store.GetCommentSubtree(ctx, rootID, maxDepth)
+	if err != nil {
+		return nil, fmt.Errorf("не удалось получить дерево комментариев: %w", err)
+	}
+	if len(subtree) == 0 {
+		return nil, storage.ErrCommentNotFound
+	}
+
+	// комментарии отсортированы по path, поэтому родитель всегда встречается раньше ответов
+	nodes := make(map[int]*model.Comment, len(subtree))
+	for i := range subtree {
+		node := &subtree[i]
+		node.Children = []*model.Comment{}
+		nodes[node.ID] = node
+		if i == 0 || node.ParentCommentID == nil {
+			continue
+		}
+		if parent, ok := nodes[*node.ParentCommentID]; ok {
+			parent.Children = append(parent.Children, node)
+		}
+	}
+	return &subtree[0], nil
+}
+
+// GetRepliesByParents Прямые ответы сразу для нескольких комментариев
+func (s *CommentService) GetRepliesByParents(ctx context.Context, parentIDs []int) (map[int][]model.Comment, error) {
+	replies, err := s.store.GetRepliesByParents(ctx, parentIDs)
+	if err != nil {
+		return nil, fmt.Errorf("не удалось получить ответы на комментарии: %w", err)
+	}
+	return replies, nil
+}
+
+// GetCommentAncestors Цепочка предков комментария от корневого до родителя
+func (s *CommentService) GetCommentAncestors(ctx context.Context, id int) ([]model.Comment, error) {
+	ancestors, err := s.store.GetCommentAncestors(ctx, id)
+	if err != nil {
+		return nil, fmt.Errorf("не удалось получить цепочку родительских комментариев: %w", err)
+	}
+	return ancestors, nil
+}
+
+// GetCommentCounts Количество прямых ответов и всех потомков для набора комментариев
+func (s *CommentService) GetCommentCounts(ctx context.Context, ids []int) (map[int]model.CommentCounts, error) {
+	counts, err := s.store.GetCommentCounts(ctx, ids)
+	if err != nil {
+		return nil, fmt.Errorf("не удалось получить количество ответов: %w", err)
+	}
+	return counts, nil
+}
+
+func (s *CommentService) EditComment(ctx context.Context, id int, content string) (*model.Comment, error) {
+	if err := validateContent(content); err != nil {
+		return nil, err
+	}
+
+	comment, err := s.store.EditComment(ctx, id, content)
+	if err != nil {
+		return nil, fmt.Errorf("не удалось отредактировать комментарий: %w", err)
+	}
+	return comment, nil
+}
+
+func (s *CommentService) GetCommentRevisions(ctx context.Context, commentID int) ([]model.CommentRevision, error) {
+	revisions, err := s.store.GetCommentRevisions(ctx, commentID)
+	if err != nil {
+		return nil, fmt.Errorf("не удалось получить историю изменений комментария: %w", err)
+	}
+	return revisions, nil
+}
+
+// GetCommentByID Получение комментария по ID
+func (s *CommentService) GetCommentByID(ctx context.Context, id int) (*model.Comment, error) {
+	comment, err := s.store.GetCommentByID(ctx, id)
+	if err != nil {
+		return nil, fmt.Errorf("не удалось получить комментарий: %w", err)
+	}
+	return comment, nil
+}
+
+// GetCommentsByIDs Комментарии по набору id (для даталоадера), не найденных id в ответе нет
+func (s *CommentService) GetCommentsByIDs(ctx context.Context, ids []int) (map[int]model.Comment, error) {
+	comments, err := s.store.GetCommentsByIDs(ctx, ids)
+	if err != nil {
+		return nil, fmt.Errorf("не удалось получить комментарии: %w", err)
+	}
+	return comments, nil
+}
+
+// GetRevisionsByComments История изменений сразу для нескольких комментариев
+func (s *CommentService) GetRevisionsByComments(ctx context.Context, commentIDs []int) (map[int][]model.CommentRevision, error) {
+	revisions, err := s.store.GetRevisionsByComments(ctx, commentIDs)
+	if err != nil {
+		return nil, fmt.Errorf("не удалось получить историю изменений комментариев: %w", err)
+	}
+	return revisions, nil
+}
+
+func (s *CommentService) DeleteComment(ctx context.Context, id int) (*model.Comment, error) {
+	comment, err := s.store.DeleteComment(ctx, id)
+	if err != nil {
+		return nil, fmt.Errorf("не удалось удалить комментарий: %w", err)
+	}
+	return comment, nil
+}
+
+// validateContent общая проверка текста для создания и редактирования комментария
+func validateContent(content string) error {
+	if content == "" {
+		return service.NewValidationError("content", service.KeyCommentRequired, "комментарий не может быть пустым")
+	}
+	if len([]rune(content)) > 2000 {
+		return service.NewValidationError("content", service.KeyCommentTooLong, "длина комментария не должна превышать 2000 символов")
+	}
+	return nil
+}
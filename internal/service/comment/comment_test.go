@@ -3,6 +3,7 @@ package comment
 import (
 	"OzonTestTask/internal/mocks"
 	"OzonTestTask/internal/model"
+	"OzonTestTask/internal/service"
 	"context"
 	"testing"
 
@@ -111,6 +112,71 @@ func TestCreateComment_CommentsNotAllowed(t *testing.T) {
 	err := commentService.CreateComment(ctx, comment)
 	assert.Error(t, err)
 	assert.Contains(t, err.Error(), "этот пост запрещено комментировать")
+	assert.ErrorIs(t, err, service.ErrCommentsDisabled)
 
 	mockStorage.AssertExpectations(t)
 }
+
+func TestEditComment_EmptyContent(t *testing.T) {
+	mockStorage := new(mocks.CommentStorage)
+	commentService := NewCommentService(mockStorage, nil)
+
+	comment, err := commentService.EditComment(ctx, 1, "")
+	assert.Nil(t, comment)
+	assert.Error(t, err)
+	assert.Contains(t, err.Error(), "комментарий не может быть пустым")
+
+	mockStorage.AssertNotCalled(t, "EditComment", mock.Anything, mock.Anything, mock.Anything)
+}
+
+func TestEditComment_TooLongComment(t *testing.T) {
+	mockStorage := new(mocks.CommentStorage)
+	commentService := NewCommentService(mockStorage, nil)
+
+	contentRunes := make([]rune, 2001)
+	comment, err := commentService.EditComment(ctx, 1, string(contentRunes))
+	assert.Nil(t, comment)
+	assert.Error(t, err)
+	assert.Contains(t, err.Error(), "длина комментария не должна превышать 2000 символов")
+
+	mockStorage.AssertNotCalled(t, "EditComment", mock.Anything, mock.Anything, mock.Anything)
+}
+
+func TestGetCommentTree(t *testing.T) {
+	mockStorage := new(mocks.CommentStorage)
+	commentService := NewCommentService(mockStorage, nil)
+
+	rootID, c1ID := 1, 2
+	mockStorage.On("GetCommentSubtree", mock.Anything, rootID, 2).Return([]model.Comment{
+		{ID: rootID, Path: "1", ChildCount: 2},
+		{ID: c1ID, ParentCommentID: &rootID, Path: "1.2", ChildCount: 1},
+		{ID: 3, ParentCommentID: &c1ID, Path: "1.2.3", ChildCount: 4},
+		{ID: 5, ParentCommentID: &rootID, Path: "1.5"},
+	}, nil)
+
+	tree, err := commentService.GetCommentTree(ctx, rootID, 2)
+	assert.NoError(t, err)
+	assert.Equal(t, rootID, tree.ID)
+	assert.Len(t, tree.Children, 2)
+	assert.Equal(t, c1ID, tree.Children[0].ID)
+	assert.Equal(t, 5, tree.Children[1].ID)
+	assert.Len(t, tree.Children[0].Children, 1)
+
+	collapsed := tree.Children[0].Children[0]
+	assert.NotNil(t, collapsed.Children)
+	assert.Empty(t, collapsed.Children)
+	assert.Equal(t, 4, collapsed.ChildCount)
+
+	mockStorage.AssertExpectations(t)
+}
+
+func TestGetCommentTree_NotFound(t *testing.T) {
+	mockStorage := new(mocks.CommentStorage)
+	commentService := NewCommentService(mockStorage, nil)
+
+	mockStorage.On("GetCommentSubtree", mock.Anything, 1, 0).Return([]model.Comment{}, nil)
+
+	tree, err := commentService.GetCommentTree(ctx, 1, 0)
+	assert.Nil(t, tree)
+	assert.Error(t, err)
+}
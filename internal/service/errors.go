@@ -0,0 +1,63 @@
+package service
+
+import (
+	"OzonTestTask/internal/storage"
+	"errors"
+)
+
+// Виды ошибок сервисов. Сервисы оборачивают ошибки через %w, поэтому вид ошибки
+// сохраняется на всех слоях и проверяется через errors.Is
+var (
+	ErrNotFound         = storage.ErrNotFound
+	ErrConflict         = storage.ErrConflict
+	ErrCommentsDisabled = storage.ErrCommentsDisabled
+	ErrValidation       = errors.New("некорректные входные данные")
+
+	ErrPostNotFound    = storage.ErrPostNotFound
+	ErrCommentNotFound = storage.ErrCommentNotFound
+)
+
+// Ключи сообщений ошибок валидации в каталоге переводов
+const (
+	KeyTitleRequired       = "TITLE_REQUIRED"
+	KeyPostContentRequired = "POST_CONTENT_REQUIRED"
+	KeyAuthorRequired      = "AUTHOR_REQUIRED"
+	KeyCommentRequired     = "COMMENT_REQUIRED"
+	KeyCommentTooLong      = "COMMENT_TOO_LONG"
+	KeyInvalidID           = "INVALID_ID"
+	KeyInvalidCursor       = "INVALID_CURSOR"
+	KeyFirstAndLast        = "FIRST_AND_LAST"
+	KeyInvalidPageSize     = "INVALID_PAGE_SIZE"
+	KeyInvalidMaxDepth     = "INVALID_MAX_DEPTH"
+	KeyTooManyIDs          = "TOO_MANY_IDS"
+	KeyInvalidDateTime     = "INVALID_DATE_TIME"
+)
+
+// MessageKeyer Ошибка, у которой есть ключ сообщения в каталоге переводов
+type MessageKeyer interface {
+	MessageKey() string
+}
+
+// ValidationError Ошибка проверки входных данных. Field - имя аргумента в схеме,
+// чтобы клиент мог показать ошибку рядом с нужным полем формы, Key - ключ сообщения в каталоге переводов
+type ValidationError struct {
+	Field   string
+	Key     string
+	Message string
+}
+
+func NewValidationError(field, key, message string) *ValidationError {
+	return &ValidationError{Field: field, Key: key, Message: message}
+}
+
+func (e *ValidationError) Error() string {
+	return e.Message
+}
+
+func (e *ValidationError) MessageKey() string {
+	return e.Key
+}
+
+func (e *ValidationError) Unwrap() error {
+	return ErrValidation
+}
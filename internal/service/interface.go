@@ -2,18 +2,36 @@ package service
 
 import (
 	"OzonTestTask/internal/model"
+	"OzonTestTask/internal/pagination"
 	"context"
 )
 
 type CommentService interface {
 	CreateComment(ctx context.Context, comment *model.Comment) error
+	GetCommentByID(ctx context.Context, id int) (*model.Comment, error)
+	GetCommentsByIDs(ctx context.Context, ids []int) (map[int]model.Comment, error)
 	GetCommentsByPost(ctx context.Context, postID int, limit, offset int) ([]model.Comment, int, error)
-	GetReplies(ctx context.Context, parentCommentID int) ([]model.Comment, error)
+	GetCommentsByPosts(ctx context.Context, postIDs []int, limit, offset int) (map[int][]model.Comment, map[int]int, error)
+	GetCommentsPage(ctx context.Context, postID int, page pagination.Page) ([]model.Comment, bool, error)
+	GetReplies(ctx context.Context, parentCommentID int, page pagination.RepliesPage) ([]model.Comment, error)
+	GetRepliesByParents(ctx context.Context, parentIDs []int) (map[int][]model.Comment, error)
+	GetCommentTree(ctx context.Context, rootID int, maxDepth int) (*model.Comment, error)
+	GetCommentAncestors(ctx context.Context, id int) ([]model.Comment, error)
+	GetCommentCounts(ctx context.Context, ids []int) (map[int]model.CommentCounts, error)
+	EditComment(ctx context.Context, id int, content string) (*model.Comment, error)
+	GetCommentRevisions(ctx context.Context, commentID int) ([]model.CommentRevision, error)
+	GetRevisionsByComments(ctx context.Context, commentIDs []int) (map[int][]model.CommentRevision, error)
+	DeleteComment(ctx context.Context, id int) (*model.Comment, error)
 	GetPostByID(ctx context.Context, id int) (*model.Post, error)
 }
 
 type PostService interface {
 	CreatePost(ctx context.Context, post *model.Post) error
-	GetAllPosts(ctx context.Context) ([]model.Post, error)
+	GetAllPosts(ctx context.Context, filter model.PostsFilter) ([]model.Post, error)
+	GetPostsPage(ctx context.Context, page pagination.Page) ([]model.Post, bool, error)
 	GetPostByID(ctx context.Context, id int) (*model.Post, error)
+	GetPostsByIDs(ctx context.Context, ids []int) (map[int]model.Post, error)
+	UpdatePost(ctx context.Context, post *model.Post) error
+	DeletePost(ctx context.Context, id int) error
+	SetCommentsAllowed(ctx context.Context, id int, allowed bool) (*model.Post, error)
 }
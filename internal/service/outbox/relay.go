@@ -0,0 +1,95 @@
+package outbox
+
+import (
+	"OzonTestTask/internal/model"
+	"OzonTestTask/internal/storage"
+	"OzonTestTask/internal/subscription"
+	"context"
+	"fmt"
+	"log"
+	"time"
+)
+
+const (
+	// DefaultPollInterval как часто relay проверяет outbox, если новых уведомлений не было
+	DefaultPollInterval = 200 * time.Millisecond
+	// DefaultRetention сколько хранятся отправленные уведомления: по ним подписчики
+	// догружают снимки комментариев, не поместившихся в NOTIFY
+	DefaultRetention = time.Hour
+	purgeInterval    = time.Minute
+	batchSize        = 100
+)
+
+// Relay Отправка уведомлений из outbox подписчикам. Уведомление помечается отправленным только после
+// успешного Publish, поэтому при сбое между ними оно уйдет повторно: доставка "хотя бы один раз".
+// Пачки захватываются в хранилище, поэтому relay можно запускать в нескольких экземплярах приложения
+type Relay struct {
+	store     storage.OutboxStorage
+	sub       subscription.Subscription
+	interval  time.Duration
+	retention time.Duration
+}
+
+func NewRelay(store storage.OutboxStorage, sub subscription.Subscription, interval time.Duration) *Relay {
+	return &Relay{
+		store:     store,
+		sub:       sub,
+		interval:  interval,
+		retention: DefaultRetention,
+	}
+}
+
+// Run Отправка уведомлений до отмены ctx. Ошибки хранилища и подписок не останавливают relay -
+// неотправленные уведомления остаются в outbox до следующей попытки. Раз в purgeInterval
+// из outbox удаляются уведомления, отправленные раньше, чем retention назад
+func (r *Relay) Run(ctx context.Context) {
+	ticker := time.NewTicker(r.interval)
+	defer ticker.Stop()
+	var lastPurge time.Time
+	for {
+		// полная пачка - в outbox, возможно, есть еще, забираю следующую без ожидания
+		for {
+			sent, err := r.Flush(ctx)
+			if err != nil {
+				log.Printf("relay outbox: %v", err)
+			}
+			if err != nil || sent < batchSize {
+				break
+			}
+		}
+
+		if time.Since(lastPurge) >= purgeInterval {
+			if _, err := r.Purge(ctx); err != nil {
+				log.Printf("relay outbox: %v", err)
+			}
+			lastPurge = time.Now()
+		}
+
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+	}
+}
+
+// Flush Один проход по outbox: отправка пачки уведомлений и пометка отправленных.
+// После первой ошибки Publish остальные уведомления пачки откладываются, чтобы не нарушать их порядок
+func (r *Relay) Flush(ctx context.Context) (int, error) {
+	return r.store.DeliverNotifications(ctx, batchSize, func(message model.OutboxMessage) error {
+		comment := message.Comment
+		if err := r.sub.Publish(comment.PostID, &comment); err != nil {
+			return fmt.Errorf("не удалось отправить уведомление о комментарии %d: %w", comment.ID, err)
+		}
+		return nil
+	})
+}
+
+// Purge Удаление из outbox уведомлений, отправленных раньше, чем retention назад
+func (r *Relay) Purge(ctx context.Context) (int, error) {
+	purged, err := r.store.PurgeDeliveredNotifications(ctx, time.Now().UTC().Add(-r.retention))
+	if err != nil {
+		return 0, fmt.Errorf("не удалось очистить outbox: %w", err)
+	}
+	return purged, nil
+}
@@ -0,0 +1,132 @@
+package outbox
+
+import (
+	"OzonTestTask/internal/mocks"
+	"OzonTestTask/internal/model"
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/mock"
+	"github.com/stretchr/testify/require"
+)
+
+var ctx = context.Background()
+
+// deliver Поведение хранилища: уведомления отдаются publish по порядку до первой ошибки,
+// в delivered попадают id тех, что отправлены
+func deliver(messages []model.OutboxMessage, delivered *[]int) func(context.Context, int, func(model.OutboxMessage) error) (int, error) {
+	return func(_ context.Context, _ int, publish func(model.OutboxMessage) error) (int, error) {
+		for _, message := range messages {
+			if err := publish(message); err != nil {
+				return len(*delivered), err
+			}
+			*delivered = append(*delivered, message.ID)
+		}
+		return len(*delivered), nil
+	}
+}
+
+func TestFlush(t *testing.T) {
+	mockStorage := new(mocks.OutboxStorage)
+	mockSubscription := new(mocks.Subscription)
+	relay := NewRelay(mockStorage, mockSubscription, DefaultPollInterval)
+
+	messages := []model.OutboxMessage{
+		{ID: 1, Comment: model.Comment{ID: 10, PostID: 1, Content: "Первый"}},
+		{ID: 2, Comment: model.Comment{ID: 11, PostID: 2, Content: "Второй"}},
+	}
+	var delivered []int
+	mockStorage.On("DeliverNotifications", mock.Anything, batchSize, mock.Anything).Return(deliver(messages, &delivered))
+	mockSubscription.On("Publish", 1, &messages[0].Comment).Return(nil)
+	mockSubscription.On("Publish", 2, &messages[1].Comment).Return(nil)
+
+	sent, err := relay.Flush(ctx)
+	require.NoError(t, err)
+	assert.Equal(t, 2, sent)
+	assert.Equal(t, []int{1, 2}, delivered)
+	mockStorage.AssertExpectations(t)
+	mockSubscription.AssertExpectations(t)
+}
+
+func TestFlush_PublishError(t *testing.T) {
+	mockStorage := new(mocks.OutboxStorage)
+	mockSubscription := new(mocks.Subscription)
+	relay := NewRelay(mockStorage, mockSubscription, DefaultPollInterval)
+
+	messages := []model.OutboxMessage{
+		{ID: 1, Comment: model.Comment{ID: 10, PostID: 1}},
+		{ID: 2, Comment: model.Comment{ID: 11, PostID: 1}},
+		{ID: 3, Comment: model.Comment{ID: 12, PostID: 1}},
+	}
+	var delivered []int
+	mockStorage.On("DeliverNotifications", mock.Anything, batchSize, mock.Anything).Return(deliver(messages, &delivered))
+	mockSubscription.On("Publish", 1, &messages[0].Comment).Return(nil)
+	mockSubscription.On("Publish", 1, &messages[1].Comment).Return(errors.New("соединение потеряно"))
+
+	// отправленным помечается только то, что ушло до ошибки; остальное останется в outbox
+	sent, err := relay.Flush(ctx)
+	require.Error(t, err)
+	assert.Equal(t, 1, sent)
+	assert.Equal(t, []int{1}, delivered)
+	mockSubscription.AssertNotCalled(t, "Publish", 1, &messages[2].Comment)
+}
+
+func TestPurge(t *testing.T) {
+	mockStorage := new(mocks.OutboxStorage)
+	relay := NewRelay(mockStorage, new(mocks.Subscription), DefaultPollInterval)
+
+	// удаляются уведомления, отправленные раньше, чем DefaultRetention назад
+	mockStorage.On("PurgeDeliveredNotifications", mock.Anything, mock.MatchedBy(func(before time.Time) bool {
+		age := time.Since(before)
+		return age >= DefaultRetention && age < DefaultRetention+time.Minute
+	})).Return(3, nil)
+
+	purged, err := relay.Purge(ctx)
+	require.NoError(t, err)
+	assert.Equal(t, 3, purged)
+	mockStorage.AssertExpectations(t)
+}
+
+func TestRun_RetriesUntilDelivered(t *testing.T) {
+	mockStorage := new(mocks.OutboxStorage)
+	mockSubscription := new(mocks.Subscription)
+	relay := NewRelay(mockStorage, mockSubscription, 10*time.Millisecond)
+
+	message := model.OutboxMessage{ID: 1, Comment: model.Comment{ID: 10, PostID: 1}}
+	delivered := make(chan []int, 1)
+	var firstAttempt, secondAttempt []int
+	mockStorage.On("DeliverNotifications", mock.Anything, batchSize, mock.Anything).
+		Return(deliver([]model.OutboxMessage{message}, &firstAttempt)).Once()
+	mockStorage.On("DeliverNotifications", mock.Anything, batchSize, mock.Anything).
+		Return(func(ctx context.Context, limit int, publish func(model.OutboxMessage) error) (int, error) {
+			sent, err := deliver([]model.OutboxMessage{message}, &secondAttempt)(ctx, limit, publish)
+			delivered <- secondAttempt
+			return sent, err
+		}).Once()
+	mockStorage.On("DeliverNotifications", mock.Anything, batchSize, mock.Anything).Return(0, nil)
+	mockStorage.On("PurgeDeliveredNotifications", mock.Anything, mock.Anything).Return(0, nil)
+	// первая попытка отправки падает, уведомление уходит со второй
+	mockSubscription.On("Publish", 1, mock.Anything).Return(errors.New("соединение потеряно")).Once()
+	mockSubscription.On("Publish", 1, mock.Anything).Return(nil).Once()
+
+	runCtx, cancel := context.WithCancel(ctx)
+	done := make(chan struct{})
+	go func() {
+		relay.Run(runCtx)
+		close(done)
+	}()
+
+	select {
+	case ids := <-delivered:
+		assert.Equal(t, []int{1}, ids)
+	case <-time.After(time.Second):
+		t.Fatal("уведомление не отправлено повторно")
+	}
+	cancel()
+	<-done
+	assert.Empty(t, firstAttempt)
+	mockSubscription.AssertExpectations(t)
+}
@@ -2,6 +2,8 @@ package post
 
 import (
 	"OzonTestTask/internal/model"
+	"OzonTestTask/internal/pagination"
+	"OzonTestTask/internal/service"
 	"OzonTestTask/internal/storage"
 	"context"
 	"fmt"
@@ -17,33 +19,84 @@ func NewPostService(s storage.PostStorage) *PostService {
 
 func (s *PostService) CreatePost(ctx context.Context, post *model.Post) error {
 	if post.Title == "" {
-		return fmt.Errorf("заголовок поста не может быть пустым")
+		return service.NewValidationError("title", service.KeyTitleRequired, "заголовок поста не может быть пустым")
 	}
 	if post.Content == "" {
-		return fmt.Errorf("пост не может быть пустым")
+		return service.NewValidationError("content", service.KeyPostContentRequired, "пост не может быть пустым")
 	}
 	if post.Author == "" {
-		return fmt.Errorf("имя автора не может быть пустым")
+		return service.NewValidationError("author", service.KeyAuthorRequired, "имя автора не может быть пустым")
 	}
 	err := s.store.CreatePost(ctx, post)
 	if err != nil {
-		return fmt.Errorf("не удалось создать пост: %v", err)
+		return fmt.Errorf("не удалось создать пост: %w", err)
 	}
 	return nil
 }
 
-func (s *PostService) GetAllPosts(ctx context.Context) ([]model.Post, error) {
-	posts, err := s.store.GetAllPosts(ctx)
+func (s *PostService) GetAllPosts(ctx context.Context, filter model.PostsFilter) ([]model.Post, error) {
+	if filter.Order == "" {
+		filter.Order = model.PostOrderCreatedAt
+	}
+	posts, err := s.store.GetAllPosts(ctx, filter)
 	if err != nil {
-		return nil, fmt.Errorf("не удалось получить список постов: %v", err)
+		return nil, fmt.Errorf("не удалось получить список постов: %w", err)
 	}
 	return posts, nil
 }
 
+// GetPostsPage Страница ленты постов; второе значение - есть ли еще посты в направлении пагинации
+func (s *PostService) GetPostsPage(ctx context.Context, page pagination.Page) ([]model.Post, bool, error) {
+	posts, hasMore, err := s.store.GetPostsPage(ctx, page)
+	if err != nil {
+		return nil, false, fmt.Errorf("не удалось получить страницу постов: %w", err)
+	}
+	return posts, hasMore, nil
+}
+
 func (s *PostService) GetPostByID(ctx context.Context, id int) (*model.Post, error) {
 	post, err := s.store.GetPostByID(ctx, id)
 	if err != nil {
-		return nil, fmt.Errorf("не удалось получить пост: %v", err)
+		return nil, fmt.Errorf("не удалось получить пост: %w", err)
+	}
+	return post, nil
+}
+
+// GetPostsByIDs Посты по набору id (для даталоадера), не найденных id в ответе нет
+func (s *PostService) GetPostsByIDs(ctx context.Context, ids []int) (map[int]model.Post, error) {
+	posts, err := s.store.GetPostsByIDs(ctx, ids)
+	if err != nil {
+		return nil, fmt.Errorf("не удалось получить посты: %w", err)
+	}
+	return posts, nil
+}
+
+func (s *PostService) UpdatePost(ctx context.Context, post *model.Post) error {
+	if post.Title == "" {
+		return service.NewValidationError("title", service.KeyTitleRequired, "заголовок поста не может быть пустым")
+	}
+	if post.Content == "" {
+		return service.NewValidationError("content", service.KeyPostContentRequired, "пост не может быть пустым")
+	}
+	err := s.store.UpdatePost(ctx, post)
+	if err != nil {
+		return fmt.Errorf("не удалось обновить пост: %w", err)
+	}
+	return nil
+}
+
+func (s *PostService) DeletePost(ctx context.Context, id int) error {
+	err := s.store.DeletePost(ctx, id)
+	if err != nil {
+		return fmt.Errorf("не удалось удалить пост: %w", err)
+	}
+	return nil
+}
+
+func (s *PostService) SetCommentsAllowed(ctx context.Context, id int, allowed bool) (*model.Post, error) {
+	post, err := s.store.SetCommentsAllowed(ctx, id, allowed)
+	if err != nil {
+		return nil, fmt.Errorf("не удалось изменить разрешение на комментирование поста: %w", err)
 	}
 	return post, nil
 }
@@ -2,9 +2,11 @@ package post
 
 import (
 	"OzonTestTask/internal/model"
+	"OzonTestTask/internal/service"
 	"OzonTestTask/internal/storage/in-memory"
 	"context"
 	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
 	"testing"
 )
 
@@ -34,6 +36,10 @@ func TestCreatePost_EmptyTitle(t *testing.T) {
 	err := postService.CreatePost(ctx, post)
 	assert.Error(t, err)
 	assert.Contains(t, err.Error(), "заголовок поста не может быть пустым")
+
+	var validationErr *service.ValidationError
+	require.ErrorAs(t, err, &validationErr)
+	assert.Equal(t, "title", validationErr.Field)
 }
 
 func TestCreatePost_EmptyContent(t *testing.T) {
@@ -59,3 +65,25 @@ func TestCreatePost_EmptyAuthor(t *testing.T) {
 	assert.Error(t, err)
 	assert.Contains(t, err.Error(), "имя автора не может быть пустым")
 }
+
+func TestUpdatePost_EmptyTitle(t *testing.T) {
+	post := &model.Post{
+		ID:      1,
+		Title:   "",
+		Content: "Содержимое",
+	}
+	err := postService.UpdatePost(ctx, post)
+	assert.Error(t, err)
+	assert.Contains(t, err.Error(), "заголовок поста не может быть пустым")
+}
+
+func TestUpdatePost_EmptyContent(t *testing.T) {
+	post := &model.Post{
+		ID:      1,
+		Title:   "Заголовок",
+		Content: "",
+	}
+	err := postService.UpdatePost(ctx, post)
+	assert.Error(t, err)
+	assert.Contains(t, err.Error(), "пост не может быть пустым")
+}
@@ -0,0 +1,38 @@
+package storage
+
+import "errors"
+
+// Виды ошибок хранилища. Конкретные ошибки ниже оборачивают их,
+// поэтому сервисы и резолверы проверяют вид через errors.Is, а не по тексту
+var (
+	ErrNotFound         = errors.New("объект не найден")
+	ErrConflict         = errors.New("операция противоречит текущему состоянию объекта")
+	ErrCommentsDisabled = errors.New("этот пост запрещено комментировать")
+)
+
+var (
+	ErrPostNotFound          = &kindError{kind: ErrNotFound, key: "POST_NOT_FOUND", message: "пост не найден"}
+	ErrCommentNotFound       = &kindError{kind: ErrNotFound, key: "COMMENT_NOT_FOUND", message: "комментарий не найден"}
+	ErrParentCommentNotFound = &kindError{kind: ErrNotFound, key: "PARENT_COMMENT_NOT_FOUND", message: "комментарий для ответа не найден"}
+	ErrCommentDeleted        = &kindError{kind: ErrConflict, key: "COMMENT_DELETED", message: "удаленный комментарий нельзя редактировать"}
+)
+
+// kindError Ошибка со своим текстом, которая через errors.Is распознается как ошибка вида kind.
+// key - ключ сообщения в каталоге переводов
+type kindError struct {
+	kind    error
+	key     string
+	message string
+}
+
+func (e *kindError) Error() string {
+	return e.message
+}
+
+func (e *kindError) MessageKey() string {
+	return e.key
+}
+
+func (e *kindError) Unwrap() error {
+	return e.kind
+}
@@ -2,12 +2,15 @@ package in_memory
 
 import (
 	"context"
-	"fmt"
+	"sort"
 	"strconv"
+	"strings"
 	"sync"
 	"time"
 
 	"OzonTestTask/internal/model"
+	"OzonTestTask/internal/pagination"
+	store "OzonTestTask/internal/storage"
 )
 
 type InMemoryStorage struct {
@@ -17,9 +20,12 @@ type InMemoryStorage struct {
 	comments         map[int]model.Comment
 	commentsByPost   map[int][]int
 	replies          map[int][]int
+	descendants      map[int]int
+	revisions        map[int][]model.CommentRevision
 
-	nextPostID    int
-	nextCommentID int
+	nextPostID     int
+	nextCommentID  int
+	nextRevisionID int
 }
 
 func NewInMemoryStorage() *InMemoryStorage {
@@ -28,8 +34,11 @@ func NewInMemoryStorage() *InMemoryStorage {
 		comments:       make(map[int]model.Comment),
 		commentsByPost: make(map[int][]int),
 		replies:        make(map[int][]int),
+		descendants:    make(map[int]int),
+		revisions:      make(map[int][]model.CommentRevision),
 		nextPostID:     1,
 		nextCommentID:  1,
+		nextRevisionID: 1,
 	}
 }
 
@@ -52,19 +61,78 @@ func (ms *InMemoryStorage) CreatePost(ctx context.Context, post *model.Post) err
 }
 
 // GetAllPosts Получение всех постов
-func (ms *InMemoryStorage) GetAllPosts(ctx context.Context) ([]model.Post, error) {
+func (ms *InMemoryStorage) GetAllPosts(ctx context.Context, filter model.PostsFilter) ([]model.Post, error) {
 	ms.mu.RLock()
 	defer ms.mu.RUnlock()
 
-	posts := make([]model.Post, 0, len(ms.postsByCreatedAt))
+	// postsByCreatedAt отсортирован по времени создания, поэтому более новые посты - правее границы
+	from := 0
+	if filter.CreatedAfter != nil {
+		from = sort.Search(len(ms.postsByCreatedAt), func(i int) bool {
+			return ms.posts[ms.postsByCreatedAt[i]].CreatedAt.After(*filter.CreatedAfter)
+		})
+	}
+
+	posts := make([]model.Post, 0, len(ms.postsByCreatedAt)-from)
 	// сначала выдаю все новые посты - мне кажется, это логично для новостной ленты
-	for i := len(ms.postsByCreatedAt) - 1; i >= 0; i-- {
+	for i := len(ms.postsByCreatedAt) - 1; i >= from; i-- {
 		id := ms.postsByCreatedAt[i]
 		posts = append(posts, ms.posts[id])
 	}
+
+	// активность меняется с каждым комментарием, поэтому отдельный индекс под нее не держу
+	// и сортирую при запросе
+	if filter.Order == model.PostOrderLastActivity {
+		sort.SliceStable(posts, func(i, j int) bool {
+			a, b := posts[i].LastActivityAt(), posts[j].LastActivityAt()
+			if a.Equal(b) {
+				return posts[i].ID > posts[j].ID
+			}
+			return a.After(b)
+		})
+	}
 	return posts, nil
 }
 
+// GetPostsPage Keyset-пагинация ленты постов по (created_at, id) от новых к старым
+func (ms *InMemoryStorage) GetPostsPage(ctx context.Context, page pagination.Page) ([]model.Post, bool, error) {
+	ms.mu.RLock()
+	defer ms.mu.RUnlock()
+
+	// postsByCreatedAt отсортирован от старых к новым, а лента идет от новых к старым,
+	// поэтому "после курсора" - это левее курсора в слайсе. Кандидаты - полуинтервал [from, to)
+	from, to := 0, len(ms.postsByCreatedAt)
+	if page.After != nil {
+		to = sort.Search(len(ms.postsByCreatedAt), func(i int) bool {
+			p := ms.posts[ms.postsByCreatedAt[i]]
+			return !page.After.Less(p.CreatedAt, p.ID)
+		})
+	}
+	if page.Before != nil {
+		from = sort.Search(len(ms.postsByCreatedAt), func(i int) bool {
+			p := ms.posts[ms.postsByCreatedAt[i]]
+			return page.Before.Greater(p.CreatedAt, p.ID)
+		})
+	}
+	if from >= to {
+		return []model.Post{}, false, nil
+	}
+
+	hasMore := to-from > page.Limit
+	if page.Backward {
+		// last: ближайшие к before посты, т.е. самые старые из кандидатов
+		to = min(to, from+page.Limit)
+	} else {
+		from = max(from, to-page.Limit)
+	}
+
+	posts := make([]model.Post, 0, to-from)
+	for i := to - 1; i >= from; i-- {
+		posts = append(posts, ms.posts[ms.postsByCreatedAt[i]])
+	}
+	return posts, hasMore, nil
+}
+
 // GetPostByID Получение поста по ID
 func (ms *InMemoryStorage) GetPostByID(ctx context.Context, id int) (*model.Post, error) {
 	ms.mu.RLock()
@@ -72,19 +140,112 @@ func (ms *InMemoryStorage) GetPostByID(ctx context.Context, id int) (*model.Post
 
 	p, ok := ms.posts[id]
 	if !ok {
-		return nil, fmt.Errorf("пост не найден")
+		return nil, store.ErrPostNotFound
 	}
 	return &p, nil
 }
 
+// GetPostsByIDs Посты по набору id, не найденных id в ответе нет
+func (ms *InMemoryStorage) GetPostsByIDs(ctx context.Context, ids []int) (map[int]model.Post, error) {
+	ms.mu.RLock()
+	defer ms.mu.RUnlock()
+
+	posts := make(map[int]model.Post, len(ids))
+	for _, id := range ids {
+		if p, ok := ms.posts[id]; ok {
+			posts[id] = p
+		}
+	}
+	return posts, nil
+}
+
+// UpdatePost Редактирование заголовка и текста поста
+func (ms *InMemoryStorage) UpdatePost(ctx context.Context, post *model.Post) error {
+	ms.mu.Lock()
+	defer ms.mu.Unlock()
+
+	p, ok := ms.posts[post.ID]
+	if !ok {
+		return store.ErrPostNotFound
+	}
+	p.Title = post.Title
+	p.Content = post.Content
+	ms.posts[post.ID] = p
+	*post = p
+
+	return nil
+}
+
+// DeletePost Удаление поста вместе со всеми комментариями к нему
+func (ms *InMemoryStorage) DeletePost(ctx context.Context, id int) error {
+	ms.mu.Lock()
+	defer ms.mu.Unlock()
+
+	if _, ok := ms.posts[id]; !ok {
+		return store.ErrPostNotFound
+	}
+
+	// как ON DELETE CASCADE в postgres: обхожу все ветки от корневых комментариев
+	// и удаляю комментарии вместе с их списками ответов
+	stack := append([]int{}, ms.commentsByPost[id]...)
+	for len(stack) > 0 {
+		currentID := stack[len(stack)-1]
+		stack = stack[:len(stack)-1]
+
+		stack = append(stack, ms.replies[currentID]...)
+		delete(ms.replies, currentID)
+		delete(ms.descendants, currentID)
+		delete(ms.revisions, currentID)
+		delete(ms.comments, currentID)
+	}
+	delete(ms.commentsByPost, id)
+
+	for i, postID := range ms.postsByCreatedAt {
+		if postID == id {
+			ms.postsByCreatedAt = append(ms.postsByCreatedAt[:i], ms.postsByCreatedAt[i+1:]...)
+			break
+		}
+	}
+	delete(ms.posts, id)
+
+	return nil
+}
+
+// SetCommentsAllowed Разрешение или запрет комментирования поста
+func (ms *InMemoryStorage) SetCommentsAllowed(ctx context.Context, id int, allowed bool) (*model.Post, error) {
+	ms.mu.Lock()
+	defer ms.mu.Unlock()
+
+	p, ok := ms.posts[id]
+	if !ok {
+		return nil, store.ErrPostNotFound
+	}
+	p.AreCommentsAllowed = allowed
+	ms.posts[id] = p
+
+	return &p, nil
+}
+
 // CreateComment Создание комментария
 func (ms *InMemoryStorage) CreateComment(ctx context.Context, comment *model.Comment) error {
 	ms.mu.Lock()
 	defer ms.mu.Unlock()
 
-	_, ok := ms.posts[comment.PostID]
+	post, ok := ms.posts[comment.PostID]
 	if !ok {
-		return fmt.Errorf("пост для добавления комментария не найден")
+		return store.ErrPostNotFound
+	}
+	if !post.AreCommentsAllowed {
+		return store.ErrCommentsDisabled
+	}
+
+	// ответить можно только на комментарий того же поста
+	var parent model.Comment
+	if comment.ParentCommentID != nil {
+		parent, ok = ms.comments[*comment.ParentCommentID]
+		if !ok || parent.PostID != comment.PostID {
+			return store.ErrParentCommentNotFound
+		}
 	}
 
 	comment.ID = ms.nextCommentID
@@ -92,10 +253,6 @@ func (ms *InMemoryStorage) CreateComment(ctx context.Context, comment *model.Com
 	comment.CreatedAt = time.Now().UTC()
 
 	if comment.ParentCommentID != nil {
-		parent, ok := ms.comments[*comment.ParentCommentID]
-		if !ok {
-			return fmt.Errorf("комментарий для ответа не найден")
-		}
 		comment.Path = parent.Path + "." + strconv.Itoa(comment.ID)
 	} else {
 		comment.Path = strconv.Itoa(comment.ID)
@@ -103,9 +260,20 @@ func (ms *InMemoryStorage) CreateComment(ctx context.Context, comment *model.Com
 
 	ms.comments[comment.ID] = *comment
 
+	createdAt := comment.CreatedAt
+	post.CommentCount++
+	post.LastCommentAt = &createdAt
+	ms.posts[post.ID] = post
+
 	// если коммент - ответ на другой коммент - кладу его в мапу ответов
+	// и увеличиваю счетчик потомков у всех предков
 	if comment.ParentCommentID != nil {
 		ms.replies[*comment.ParentCommentID] = append(ms.replies[*comment.ParentCommentID], comment.ID)
+		ancestors := strings.Split(comment.Path, ".")
+		for _, ancestor := range ancestors[:len(ancestors)-1] {
+			ancestorID, _ := strconv.Atoi(ancestor)
+			ms.descendants[ancestorID]++
+		}
 	} else { // если коммент корневой - кладу в мапу корневых комментов
 		ms.commentsByPost[comment.PostID] = append(ms.commentsByPost[comment.PostID], comment.ID)
 	}
@@ -138,36 +306,306 @@ func (ms *InMemoryStorage) GetCommentsByPost(ctx context.Context, postID, limit,
 	return result, amount, nil
 }
 
-// GetReplies Получение ветки ответов на комментарий (все уровни вложенности)
-func (ms *InMemoryStorage) GetReplies(ctx context.Context, parentID int) ([]model.Comment, error) {
+// GetCommentsByPosts Страница корневых комментариев сразу для нескольких постов (для даталоадера)
+func (ms *InMemoryStorage) GetCommentsByPosts(ctx context.Context, postIDs []int, limit, offset int) (map[int][]model.Comment, map[int]int, error) {
+	ms.mu.RLock()
+	defer ms.mu.RUnlock()
+
+	comments := make(map[int][]model.Comment, len(postIDs))
+	amounts := make(map[int]int, len(postIDs))
+	for _, postID := range postIDs {
+		rootIDs := ms.commentsByPost[postID]
+		amounts[postID] = len(rootIDs)
+
+		from, to := min(offset, len(rootIDs)), min(offset+limit, len(rootIDs))
+		result := make([]model.Comment, 0, to-from)
+		for _, id := range rootIDs[from:to] {
+			result = append(result, ms.comments[id])
+		}
+		comments[postID] = result
+	}
+	return comments, amounts, nil
+}
+
+// GetCommentsPage Keyset-пагинация корневых комментариев по (created_at, id) от старых к новым
+func (ms *InMemoryStorage) GetCommentsPage(ctx context.Context, postID int, page pagination.Page) ([]model.Comment, bool, error) {
+	ms.mu.RLock()
+	defer ms.mu.RUnlock()
+
+	// корневые комментарии лежат в порядке создания, кандидаты - полуинтервал [from, to)
+	rootIDs := ms.commentsByPost[postID]
+	from, to := 0, len(rootIDs)
+	if page.After != nil {
+		from = sort.Search(len(rootIDs), func(i int) bool {
+			c := ms.comments[rootIDs[i]]
+			return page.After.Greater(c.CreatedAt, c.ID)
+		})
+	}
+	if page.Before != nil {
+		to = sort.Search(len(rootIDs), func(i int) bool {
+			c := ms.comments[rootIDs[i]]
+			return !page.Before.Less(c.CreatedAt, c.ID)
+		})
+	}
+	if from >= to {
+		return []model.Comment{}, false, nil
+	}
+
+	hasMore := to-from > page.Limit
+	if page.Backward {
+		from = max(from, to-page.Limit)
+	} else {
+		to = min(to, from+page.Limit)
+	}
+
+	result := make([]model.Comment, 0, to-from)
+	for _, id := range rootIDs[from:to] {
+		result = append(result, ms.comments[id])
+	}
+	return result, hasMore, nil
+}
+
+// GetReplies Получение ветки ответов на комментарий с ограничением глубины и страницей прямых ответов
+func (ms *InMemoryStorage) GetReplies(ctx context.Context, parentID int, page pagination.RepliesPage) ([]model.Comment, error) {
 	ms.mu.RLock()
 	defer ms.mu.RUnlock()
 
 	if _, ok := ms.comments[parentID]; !ok {
-		return nil, fmt.Errorf("комментарий не найден")
+		return nil, store.ErrCommentNotFound
+	}
+
+	// страница прямых ответов: ответы лежат в порядке создания, т.е. по возрастанию id
+	siblings := ms.replies[parentID]
+	from := sort.SearchInts(siblings, page.AfterID+1)
+	to := len(siblings)
+	if page.First > 0 {
+		to = min(to, from+page.First)
 	}
+	siblings = siblings[from:to]
 
 	// результат отдаю плоским, пускай строит клиент,
 	// чтобы не перегружать сервер при большом количестве комментов.
+	// в стеке вместе с id храню глубину относительно родителя
+	type node struct {
+		id    int
+		depth int
+	}
 	var result []model.Comment
-	stack := []int{}
+	stack := []node{}
 
-	replies := ms.replies[parentID]
-	for i := len(replies) - 1; i >= 0; i-- {
-		stack = append(stack, replies[i])
+	for i := len(siblings) - 1; i >= 0; i-- {
+		stack = append(stack, node{id: siblings[i], depth: 1})
 	}
 
 	for len(stack) > 0 {
-		currentID := stack[len(stack)-1]
+		current := stack[len(stack)-1]
 		stack = stack[:len(stack)-1]
 
-		result = append(result, ms.comments[currentID])
+		result = append(result, ms.comments[current.id])
 
-		replies := ms.replies[currentID]
+		if page.MaxDepth > 0 && current.depth >= page.MaxDepth {
+			continue
+		}
+		replies := ms.replies[current.id]
 		for i := len(replies) - 1; i >= 0; i-- {
-			stack = append(stack, replies[i])
+			stack = append(stack, node{id: replies[i], depth: current.depth + 1})
 		}
 	}
 
 	return result, nil
 }
+
+// GetRepliesByParents Прямые ответы сразу для нескольких комментариев по возрастанию id (для даталоадера)
+func (ms *InMemoryStorage) GetRepliesByParents(ctx context.Context, parentIDs []int) (map[int][]model.Comment, error) {
+	ms.mu.RLock()
+	defer ms.mu.RUnlock()
+
+	replies := make(map[int][]model.Comment, len(parentIDs))
+	for _, id := range parentIDs {
+		replies[id] = make([]model.Comment, 0, len(ms.replies[id]))
+		for _, replyID := range ms.replies[id] {
+			replies[id] = append(replies[id], ms.comments[replyID])
+		}
+	}
+	return replies, nil
+}
+
+// EditComment Редактирование текста комментария с сохранением предыдущей версии
+func (ms *InMemoryStorage) EditComment(ctx context.Context, id int, content string) (*model.Comment, error) {
+	ms.mu.Lock()
+	defer ms.mu.Unlock()
+
+	c, ok := ms.comments[id]
+	if !ok {
+		return nil, store.ErrCommentNotFound
+	}
+	if c.Deleted {
+		return nil, store.ErrCommentDeleted
+	}
+
+	// ревизия хранит текст и время, с которого этот текст был актуален
+	revisionCreatedAt := c.CreatedAt
+	if c.EditedAt != nil {
+		revisionCreatedAt = *c.EditedAt
+	}
+	ms.revisions[id] = append(ms.revisions[id], model.CommentRevision{
+		ID:        ms.nextRevisionID,
+		CommentID: id,
+		Content:   c.Content,
+		CreatedAt: revisionCreatedAt,
+	})
+	ms.nextRevisionID++
+
+	editedAt := time.Now().UTC()
+	c.Content = content
+	c.EditedAt = &editedAt
+	ms.comments[id] = c
+
+	return &c, nil
+}
+
+// GetCommentByID Получение комментария по ID, удаленные тоже возвращаются - как и в ветке ответов
+func (ms *InMemoryStorage) GetCommentByID(ctx context.Context, id int) (*model.Comment, error) {
+	ms.mu.RLock()
+	defer ms.mu.RUnlock()
+
+	c, ok := ms.comments[id]
+	if !ok {
+		return nil, store.ErrCommentNotFound
+	}
+	return &c, nil
+}
+
+// GetCommentsByIDs Комментарии по набору id, не найденных id в ответе нет
+func (ms *InMemoryStorage) GetCommentsByIDs(ctx context.Context, ids []int) (map[int]model.Comment, error) {
+	ms.mu.RLock()
+	defer ms.mu.RUnlock()
+
+	comments := make(map[int]model.Comment, len(ids))
+	for _, id := range ids {
+		if c, ok := ms.comments[id]; ok {
+			comments[id] = c
+		}
+	}
+	return comments, nil
+}
+
+// GetCommentRevisions Получение истории изменений комментария от старых версий к новым
+func (ms *InMemoryStorage) GetCommentRevisions(ctx context.Context, commentID int) ([]model.CommentRevision, error) {
+	ms.mu.RLock()
+	defer ms.mu.RUnlock()
+
+	if _, ok := ms.comments[commentID]; !ok {
+		return nil, store.ErrCommentNotFound
+	}
+
+	revisions := make([]model.CommentRevision, len(ms.revisions[commentID]))
+	copy(revisions, ms.revisions[commentID])
+	return revisions, nil
+}
+
+// GetRevisionsByComments История изменений сразу для нескольких комментариев (для даталоадера)
+func (ms *InMemoryStorage) GetRevisionsByComments(ctx context.Context, commentIDs []int) (map[int][]model.CommentRevision, error) {
+	ms.mu.RLock()
+	defer ms.mu.RUnlock()
+
+	revisions := make(map[int][]model.CommentRevision, len(commentIDs))
+	for _, id := range commentIDs {
+		revisions[id] = make([]model.CommentRevision, len(ms.revisions[id]))
+		copy(revisions[id], ms.revisions[id])
+	}
+	return revisions, nil
+}
+
+// DeleteComment Мягкое удаление комментария: текст и история изменений стираются,
+// а сам комментарий остается в replies, чтобы не потерять ветку ответов
+func (ms *InMemoryStorage) DeleteComment(ctx context.Context, id int) (*model.Comment, error) {
+	ms.mu.Lock()
+	defer ms.mu.Unlock()
+
+	c, ok := ms.comments[id]
+	if !ok {
+		return nil, store.ErrCommentNotFound
+	}
+	c.Content = ""
+	c.Deleted = true
+	ms.comments[id] = c
+	delete(ms.revisions, id)
+
+	return &c, nil
+}
+
+// GetCommentSubtree Комментарий rootID и его ветка до глубины maxDepth (0 - без ограничений),
+// порядок тот же, что и в GetReplies - обход в глубину
+func (ms *InMemoryStorage) GetCommentSubtree(ctx context.Context, rootID int, maxDepth int) ([]model.Comment, error) {
+	ms.mu.RLock()
+	defer ms.mu.RUnlock()
+
+	if _, ok := ms.comments[rootID]; !ok {
+		return nil, store.ErrCommentNotFound
+	}
+
+	type node struct {
+		id    int
+		depth int
+	}
+	var result []model.Comment
+	stack := []node{{id: rootID}}
+
+	for len(stack) > 0 {
+		current := stack[len(stack)-1]
+		stack = stack[:len(stack)-1]
+
+		replies := ms.replies[current.id]
+		c := ms.comments[current.id]
+		c.ChildCount = len(replies)
+		result = append(result, c)
+
+		if maxDepth > 0 && current.depth >= maxDepth {
+			continue
+		}
+		for i := len(replies) - 1; i >= 0; i-- {
+			stack = append(stack, node{id: replies[i], depth: current.depth + 1})
+		}
+	}
+
+	return result, nil
+}
+
+// GetCommentAncestors Предки комментария от корневого до родителя - id предков записаны в Path по порядку
+func (ms *InMemoryStorage) GetCommentAncestors(ctx context.Context, id int) ([]model.Comment, error) {
+	ms.mu.RLock()
+	defer ms.mu.RUnlock()
+
+	c, ok := ms.comments[id]
+	if !ok {
+		return nil, store.ErrCommentNotFound
+	}
+
+	path := strings.Split(c.Path, ".")
+	ancestors := make([]model.Comment, 0, len(path)-1)
+	for _, rawID := range path[:len(path)-1] {
+		ancestorID, _ := strconv.Atoi(rawID)
+		ancestors = append(ancestors, ms.comments[ancestorID])
+	}
+	return ancestors, nil
+}
+
+// GetCommentCounts Количество прямых ответов и всех потомков - счетчики поддерживаются при создании комментариев
+func (ms *InMemoryStorage) GetCommentCounts(ctx context.Context, ids []int) (map[int]model.CommentCounts, error) {
+	ms.mu.RLock()
+	defer ms.mu.RUnlock()
+
+	counts := make(map[int]model.CommentCounts, len(ids))
+	for _, id := range ids {
+		if _, ok := ms.comments[id]; !ok {
+			continue
+		}
+		counts[id] = model.CommentCounts{
+			CommentID:       id,
+			ReplyCount:      len(ms.replies[id]),
+			DescendantCount: ms.descendants[id],
+		}
+	}
+	return counts, nil
+}
@@ -2,6 +2,8 @@ package in_memory
 
 import (
 	"OzonTestTask/internal/model"
+	"OzonTestTask/internal/pagination"
+	store "OzonTestTask/internal/storage"
 	"context"
 	"fmt"
 	"github.com/stretchr/testify/assert"
@@ -52,7 +54,7 @@ func TestGetAllPosts(t *testing.T) {
 	time.Sleep(time.Millisecond)
 	require.NoError(t, storage.CreatePost(ctx, post2), "пост не создан")
 
-	posts, err := storage.GetAllPosts(ctx)
+	posts, err := storage.GetAllPosts(ctx, model.PostsFilter{Order: model.PostOrderCreatedAt})
 	require.NoError(t, err)
 	assert.Len(t, posts, 2)
 	assert.GreaterOrEqualf(t, posts[0].CreatedAt.UnixMilli(), posts[1].CreatedAt.UnixMilli(), "должен сортироваться по дате создания")
@@ -94,6 +96,24 @@ func TestCreateComment_WrongPostID(t *testing.T) {
 	assert.Error(t, err)
 }
 
+func TestCreateComment_WrongParent(t *testing.T) {
+	conf()
+	post := &model.Post{Title: "Пост", Content: "Текст", Author: "Даша", AreCommentsAllowed: true}
+	require.NoError(t, storage.CreatePost(ctx, post), "пост не создан")
+	other := &model.Post{Title: "Другой пост", Content: "Текст", Author: "Даша", AreCommentsAllowed: true}
+	require.NoError(t, storage.CreatePost(ctx, other), "пост не создан")
+	foreign := &model.Comment{PostID: other.ID, Author: "Даша", Content: "Чужой"}
+	require.NoError(t, storage.CreateComment(ctx, foreign))
+
+	missingID := -1
+	err := storage.CreateComment(ctx, &model.Comment{PostID: post.ID, ParentCommentID: &missingID, Author: "Даша", Content: "Ответ"})
+	require.ErrorIs(t, err, store.ErrParentCommentNotFound)
+
+	// ответ на комментарий другого поста
+	err = storage.CreateComment(ctx, &model.Comment{PostID: post.ID, ParentCommentID: &foreign.ID, Author: "Даша", Content: "Ответ"})
+	require.ErrorIs(t, err, store.ErrParentCommentNotFound)
+}
+
 func TestGetCommentsByPost(t *testing.T) {
 	conf()
 
@@ -144,7 +164,7 @@ func TestGetReplies(t *testing.T) {
 	c2 := &model.Comment{PostID: post.ID, ParentCommentID: &c1.ID}
 	require.NoError(t, storage.CreateComment(ctx, c2))
 
-	replies, err := storage.GetReplies(ctx, root.ID)
+	replies, err := storage.GetReplies(ctx, root.ID, pagination.RepliesPage{})
 	require.NoError(t, err)
 	expected := []int{c1.ID, c2.ID}
 
@@ -174,7 +194,7 @@ func TestGetRepliesDeep(t *testing.T) {
 		ids = append(ids, c.ID)
 	}
 
-	replies, err := storage.GetReplies(ctx, ids[0])
+	replies, err := storage.GetReplies(ctx, ids[0], pagination.RepliesPage{})
 	require.NoError(t, err)
 	assert.Len(t, replies, len(ids)-1)
 	for i, r := range replies {
@@ -209,7 +229,7 @@ func TestGetRepliesDeepAndBranching(t *testing.T) {
 		require.NoError(t, storage.CreateComment(ctx, b), "комментарий не создан")
 	}
 
-	replies, err := storage.GetReplies(ctx, root.ID)
+	replies, err := storage.GetReplies(ctx, root.ID, pagination.RepliesPage{})
 	require.NoError(t, err)
 
 	expectedOrder := []int{
@@ -257,3 +277,575 @@ func TestPagination(t *testing.T) {
 	assert.Equal(t, 5, total)
 	assert.Len(t, comments, limit)
 }
+
+func TestUpdatePost(t *testing.T) {
+	conf()
+	post := &model.Post{Title: "Старый", Content: "Текст", Author: "Даша", AreCommentsAllowed: true}
+	require.NoError(t, storage.CreatePost(ctx, post), "пост не создан")
+
+	update := &model.Post{ID: post.ID, Title: "Новый", Content: "Исправленный текст"}
+	require.NoError(t, storage.UpdatePost(ctx, update))
+	assert.Equal(t, post.Author, update.Author)
+	assert.Equal(t, post.CreatedAt, update.CreatedAt)
+
+	updated, err := storage.GetPostByID(ctx, post.ID)
+	require.NoError(t, err)
+	assert.Equal(t, "Новый", updated.Title)
+	assert.Equal(t, "Исправленный текст", updated.Content)
+	assert.True(t, updated.AreCommentsAllowed)
+}
+
+func TestUpdatePost_WrongID(t *testing.T) {
+	conf()
+	err := storage.UpdatePost(ctx, &model.Post{ID: -1, Title: "Пост", Content: "Текст"})
+	assert.Error(t, err)
+}
+
+func TestDeletePost(t *testing.T) {
+	conf()
+	post := &model.Post{Title: "Удаляемый", AreCommentsAllowed: true}
+	require.NoError(t, storage.CreatePost(ctx, post), "пост не создан")
+	other := &model.Post{Title: "Остается", AreCommentsAllowed: true}
+	require.NoError(t, storage.CreatePost(ctx, other), "пост не создан")
+
+	root := &model.Comment{PostID: post.ID}
+	require.NoError(t, storage.CreateComment(ctx, root))
+	reply := &model.Comment{PostID: post.ID, ParentCommentID: &root.ID}
+	require.NoError(t, storage.CreateComment(ctx, reply))
+	otherRoot := &model.Comment{PostID: other.ID}
+	require.NoError(t, storage.CreateComment(ctx, otherRoot))
+
+	require.NoError(t, storage.DeletePost(ctx, post.ID))
+
+	_, err := storage.GetPostByID(ctx, post.ID)
+	assert.Error(t, err)
+	posts, err := storage.GetAllPosts(ctx, model.PostsFilter{Order: model.PostOrderCreatedAt})
+	require.NoError(t, err)
+	assert.Len(t, posts, 1)
+	assert.Equal(t, other.ID, posts[0].ID)
+
+	// комментарии удаленного поста удалены каскадно, чужие остались
+	assert.NotContains(t, storage.comments, root.ID)
+	assert.NotContains(t, storage.comments, reply.ID)
+	assert.NotContains(t, storage.replies, root.ID)
+	assert.NotContains(t, storage.commentsByPost, post.ID)
+	assert.Contains(t, storage.comments, otherRoot.ID)
+}
+
+func TestDeletePost_WrongID(t *testing.T) {
+	conf()
+	assert.Error(t, storage.DeletePost(ctx, -1))
+}
+
+func TestSetCommentsAllowed(t *testing.T) {
+	conf()
+	post := &model.Post{Title: "Пост", AreCommentsAllowed: true}
+	require.NoError(t, storage.CreatePost(ctx, post), "пост не создан")
+
+	locked, err := storage.SetCommentsAllowed(ctx, post.ID, false)
+	require.NoError(t, err)
+	assert.False(t, locked.AreCommentsAllowed)
+
+	err = storage.CreateComment(ctx, &model.Comment{PostID: post.ID, Content: "Текст"})
+	assert.Error(t, err, "в закрытый пост нельзя добавить комментарий")
+
+	_, err = storage.SetCommentsAllowed(ctx, post.ID, true)
+	require.NoError(t, err)
+	assert.NoError(t, storage.CreateComment(ctx, &model.Comment{PostID: post.ID, Content: "Текст"}))
+}
+
+func TestSetCommentsAllowed_WrongID(t *testing.T) {
+	conf()
+	post, err := storage.SetCommentsAllowed(ctx, -1, false)
+	assert.Nil(t, post)
+	assert.Error(t, err)
+}
+
+func TestEditComment(t *testing.T) {
+	conf()
+	post := &model.Post{Title: "Пост", AreCommentsAllowed: true}
+	require.NoError(t, storage.CreatePost(ctx, post), "пост не создан")
+	comment := &model.Comment{PostID: post.ID, Author: "Анна", Content: "Опечтака"}
+	require.NoError(t, storage.CreateComment(ctx, comment), "комментарий не создан")
+
+	edited, err := storage.EditComment(ctx, comment.ID, "Опечатка")
+	require.NoError(t, err)
+	assert.Equal(t, "Опечатка", edited.Content)
+	require.NotNil(t, edited.EditedAt)
+
+	_, err = storage.EditComment(ctx, comment.ID, "Опечатка исправлена")
+	require.NoError(t, err)
+
+	revisions, err := storage.GetCommentRevisions(ctx, comment.ID)
+	require.NoError(t, err)
+	require.Len(t, revisions, 2)
+	assert.Equal(t, "Опечтака", revisions[0].Content)
+	assert.Equal(t, comment.CreatedAt, revisions[0].CreatedAt)
+	assert.Equal(t, "Опечатка", revisions[1].Content)
+	assert.Equal(t, *edited.EditedAt, revisions[1].CreatedAt)
+}
+
+func TestEditComment_WrongID(t *testing.T) {
+	conf()
+	comment, err := storage.EditComment(ctx, -1, "Текст")
+	assert.Nil(t, comment)
+	assert.Error(t, err)
+}
+
+func TestDeleteComment(t *testing.T) {
+	conf()
+	post := &model.Post{Title: "Пост", AreCommentsAllowed: true}
+	require.NoError(t, storage.CreatePost(ctx, post), "пост не создан")
+
+	root := &model.Comment{PostID: post.ID, Author: "Анна", Content: "Корневой"}
+	require.NoError(t, storage.CreateComment(ctx, root))
+	c1 := &model.Comment{PostID: post.ID, ParentCommentID: &root.ID, Content: "Удаляемый"}
+	require.NoError(t, storage.CreateComment(ctx, c1))
+	c2 := &model.Comment{PostID: post.ID, ParentCommentID: &c1.ID, Content: "Ответ на удаляемый"}
+	require.NoError(t, storage.CreateComment(ctx, c2))
+	_, err := storage.EditComment(ctx, c1.ID, "Удаляемый, исправленный")
+	require.NoError(t, err)
+
+	deleted, err := storage.DeleteComment(ctx, c1.ID)
+	require.NoError(t, err)
+	assert.True(t, deleted.Deleted)
+	assert.Empty(t, deleted.Content)
+	assert.Equal(t, c1.Path, deleted.Path)
+
+	// ветка ответов сохраняется вместе с удаленным комментарием
+	replies, err := storage.GetReplies(ctx, root.ID, pagination.RepliesPage{})
+	require.NoError(t, err)
+	require.Len(t, replies, 2)
+	assert.Equal(t, c1.ID, replies[0].ID)
+	assert.True(t, replies[0].Deleted)
+	assert.Equal(t, c2.ID, replies[1].ID)
+	assert.Equal(t, "Ответ на удаляемый", replies[1].Content)
+
+	revisions, err := storage.GetCommentRevisions(ctx, c1.ID)
+	require.NoError(t, err)
+	assert.Empty(t, revisions, "история изменений удаленного комментария стирается")
+
+	_, err = storage.EditComment(ctx, c1.ID, "Воскрешение")
+	assert.Error(t, err, "удаленный комментарий нельзя редактировать")
+}
+
+func TestGetCommentByID(t *testing.T) {
+	conf()
+	post := &model.Post{Title: "Пост", AreCommentsAllowed: true}
+	require.NoError(t, storage.CreatePost(ctx, post), "пост не создан")
+	root := &model.Comment{PostID: post.ID, Author: "Анна", Content: "Корневой"}
+	require.NoError(t, storage.CreateComment(ctx, root))
+	reply := &model.Comment{PostID: post.ID, ParentCommentID: &root.ID, Author: "Олег", Content: "Ответ"}
+	require.NoError(t, storage.CreateComment(ctx, reply))
+
+	comment, err := storage.GetCommentByID(ctx, reply.ID)
+	require.NoError(t, err)
+	assert.Equal(t, "Ответ", comment.Content)
+	assert.Equal(t, post.ID, comment.PostID)
+	require.NotNil(t, comment.ParentCommentID)
+	assert.Equal(t, root.ID, *comment.ParentCommentID)
+	assert.Equal(t, reply.Path, comment.Path)
+
+	_, err = storage.GetCommentByID(ctx, -1)
+	assert.Error(t, err)
+}
+
+func TestGetPostsAndCommentsByIDs(t *testing.T) {
+	conf()
+	first := &model.Post{Title: "Первый", AreCommentsAllowed: true}
+	second := &model.Post{Title: "Второй"}
+	require.NoError(t, storage.CreatePost(ctx, first), "пост не создан")
+	require.NoError(t, storage.CreatePost(ctx, second), "пост не создан")
+	root := &model.Comment{PostID: first.ID, Author: "Анна", Content: "Корневой"}
+	require.NoError(t, storage.CreateComment(ctx, root))
+	reply := &model.Comment{PostID: first.ID, ParentCommentID: &root.ID, Author: "Олег", Content: "Ответ"}
+	require.NoError(t, storage.CreateComment(ctx, reply))
+
+	// несуществующих id в ответе просто нет
+	posts, err := storage.GetPostsByIDs(ctx, []int{first.ID, second.ID, -1})
+	require.NoError(t, err)
+	require.Len(t, posts, 2)
+	assert.Equal(t, "Первый", posts[first.ID].Title)
+	assert.Equal(t, "Второй", posts[second.ID].Title)
+
+	comments, err := storage.GetCommentsByIDs(ctx, []int{root.ID, reply.ID, -1})
+	require.NoError(t, err)
+	require.Len(t, comments, 2)
+	assert.Equal(t, "Корневой", comments[root.ID].Content)
+	require.NotNil(t, comments[reply.ID].ParentCommentID)
+	assert.Equal(t, root.ID, *comments[reply.ID].ParentCommentID)
+	assert.Equal(t, reply.Path, comments[reply.ID].Path)
+}
+
+func TestDeleteComment_WrongID(t *testing.T) {
+	conf()
+	comment, err := storage.DeleteComment(ctx, -1)
+	assert.Nil(t, comment)
+	assert.Error(t, err)
+}
+
+func TestGetPostsPage(t *testing.T) {
+	conf()
+	var ids []int
+	for i := 1; i <= 5; i++ {
+		p := &model.Post{Title: fmt.Sprintf("Пост %d", i)}
+		require.NoError(t, storage.CreatePost(ctx, p), "пост не создан")
+		ids = append(ids, p.ID)
+	}
+
+	// первая страница - самые новые посты
+	posts, hasMore, err := storage.GetPostsPage(ctx, pagination.Page{Limit: 2})
+	require.NoError(t, err)
+	assert.True(t, hasMore)
+	require.Len(t, posts, 2)
+	assert.Equal(t, ids[4], posts[0].ID)
+	assert.Equal(t, ids[3], posts[1].ID)
+
+	// после курсора последнего поста страницы - следующие по старшинству
+	after := pagination.NewCursor(posts[1].CreatedAt, posts[1].ID)
+	posts, hasMore, err = storage.GetPostsPage(ctx, pagination.Page{After: &after, Limit: 3})
+	require.NoError(t, err)
+	assert.False(t, hasMore)
+	require.Len(t, posts, 3)
+	assert.Equal(t, ids[2], posts[0].ID)
+	assert.Equal(t, ids[0], posts[2].ID)
+
+	// last/before - ближайшие более новые посты, порядок тот же (от новых к старым)
+	before := pagination.NewCursor(posts[2].CreatedAt, posts[2].ID)
+	posts, hasMore, err = storage.GetPostsPage(ctx, pagination.Page{Before: &before, Limit: 2, Backward: true})
+	require.NoError(t, err)
+	assert.True(t, hasMore)
+	require.Len(t, posts, 2)
+	assert.Equal(t, ids[2], posts[0].ID)
+	assert.Equal(t, ids[1], posts[1].ID)
+}
+
+func TestGetPostsPage_StableOnInsert(t *testing.T) {
+	conf()
+	for i := 1; i <= 3; i++ {
+		require.NoError(t, storage.CreatePost(ctx, &model.Post{Title: fmt.Sprintf("Пост %d", i)}))
+	}
+	posts, _, err := storage.GetPostsPage(ctx, pagination.Page{Limit: 2})
+	require.NoError(t, err)
+	after := pagination.NewCursor(posts[1].CreatedAt, posts[1].ID)
+
+	// новый пост не сдвигает следующую страницу
+	require.NoError(t, storage.CreatePost(ctx, &model.Post{Title: "Свежий"}))
+	next, hasMore, err := storage.GetPostsPage(ctx, pagination.Page{After: &after, Limit: 2})
+	require.NoError(t, err)
+	assert.False(t, hasMore)
+	require.Len(t, next, 1)
+	assert.Equal(t, posts[1].ID-1, next[0].ID)
+}
+
+func TestGetCommentsPage(t *testing.T) {
+	conf()
+	post := &model.Post{Title: "Пост для пагинации", AreCommentsAllowed: true}
+	require.NoError(t, storage.CreatePost(ctx, post), "пост не создан")
+
+	var ids []int
+	for i := 1; i <= 5; i++ {
+		c := &model.Comment{PostID: post.ID, Content: fmt.Sprintf("Коммент %d", i)}
+		require.NoError(t, storage.CreateComment(ctx, c))
+		ids = append(ids, c.ID)
+		// ответы не попадают в выдачу корневых комментариев
+		require.NoError(t, storage.CreateComment(ctx, &model.Comment{PostID: post.ID, ParentCommentID: &c.ID}))
+	}
+
+	comments, hasMore, err := storage.GetCommentsPage(ctx, post.ID, pagination.Page{Limit: 2})
+	require.NoError(t, err)
+	assert.True(t, hasMore)
+	require.Len(t, comments, 2)
+	assert.Equal(t, ids[0], comments[0].ID)
+	assert.Equal(t, ids[1], comments[1].ID)
+
+	// новый корневой комментарий не сдвигает следующую страницу
+	require.NoError(t, storage.CreateComment(ctx, &model.Comment{PostID: post.ID, Content: "Свежий"}))
+
+	after := pagination.NewCursor(comments[1].CreatedAt, comments[1].ID)
+	comments, hasMore, err = storage.GetCommentsPage(ctx, post.ID, pagination.Page{After: &after, Limit: 2})
+	require.NoError(t, err)
+	assert.True(t, hasMore)
+	require.Len(t, comments, 2)
+	assert.Equal(t, ids[2], comments[0].ID)
+	assert.Equal(t, ids[3], comments[1].ID)
+
+	before := pagination.NewCursor(comments[0].CreatedAt, comments[0].ID)
+	comments, hasMore, err = storage.GetCommentsPage(ctx, post.ID, pagination.Page{Before: &before, Limit: 1, Backward: true})
+	require.NoError(t, err)
+	assert.True(t, hasMore)
+	require.Len(t, comments, 1)
+	assert.Equal(t, ids[1], comments[0].ID)
+}
+
+func TestGetReplies_MaxDepthAndPaging(t *testing.T) {
+	conf()
+	post := &model.Post{Title: "Вирусный тред", AreCommentsAllowed: true}
+	require.NoError(t, storage.CreatePost(ctx, post), "пост не создан")
+
+	root := &model.Comment{PostID: post.ID}
+	require.NoError(t, storage.CreateComment(ctx, root))
+
+	// три прямых ответа, у каждого ветка глубиной 2
+	var siblings, children, grandchildren []int
+	for i := 0; i < 3; i++ {
+		s := &model.Comment{PostID: post.ID, ParentCommentID: &root.ID}
+		require.NoError(t, storage.CreateComment(ctx, s))
+		c := &model.Comment{PostID: post.ID, ParentCommentID: &s.ID}
+		require.NoError(t, storage.CreateComment(ctx, c))
+		g := &model.Comment{PostID: post.ID, ParentCommentID: &c.ID}
+		require.NoError(t, storage.CreateComment(ctx, g))
+		siblings = append(siblings, s.ID)
+		children = append(children, c.ID)
+		grandchildren = append(grandchildren, g.ID)
+	}
+
+	// два уровня, первые два прямых ответа
+	replies, err := storage.GetReplies(ctx, root.ID, pagination.RepliesPage{MaxDepth: 2, First: 2})
+	require.NoError(t, err)
+	expected := []int{siblings[0], children[0], siblings[1], children[1]}
+	require.Len(t, replies, len(expected))
+	for i, r := range replies {
+		assert.Equal(t, expected[i], r.ID)
+	}
+
+	// следующая страница прямых ответов
+	replies, err = storage.GetReplies(ctx, root.ID, pagination.RepliesPage{MaxDepth: 2, First: 2, AfterID: siblings[1]})
+	require.NoError(t, err)
+	expected = []int{siblings[2], children[2]}
+	require.Len(t, replies, len(expected))
+	for i, r := range replies {
+		assert.Equal(t, expected[i], r.ID)
+	}
+
+	// догрузка глубже из середины ветки
+	replies, err = storage.GetReplies(ctx, children[0], pagination.RepliesPage{MaxDepth: 1})
+	require.NoError(t, err)
+	require.Len(t, replies, 1)
+	assert.Equal(t, grandchildren[0], replies[0].ID)
+
+	// у комментария без ответов - пустая страница, у несуществующего - ошибка
+	replies, err = storage.GetReplies(ctx, grandchildren[0], pagination.RepliesPage{})
+	require.NoError(t, err)
+	assert.Empty(t, replies)
+	_, err = storage.GetReplies(ctx, -1, pagination.RepliesPage{})
+	require.ErrorIs(t, err, store.ErrCommentNotFound)
+}
+
+func TestGetCommentSubtree(t *testing.T) {
+	conf()
+	post := &model.Post{Title: "Пост", AreCommentsAllowed: true}
+	require.NoError(t, storage.CreatePost(ctx, post), "пост не создан")
+
+	root := &model.Comment{PostID: post.ID}
+	require.NoError(t, storage.CreateComment(ctx, root))
+	c1 := &model.Comment{PostID: post.ID, ParentCommentID: &root.ID}
+	require.NoError(t, storage.CreateComment(ctx, c1))
+	c2 := &model.Comment{PostID: post.ID, ParentCommentID: &c1.ID}
+	require.NoError(t, storage.CreateComment(ctx, c2))
+	c3 := &model.Comment{PostID: post.ID, ParentCommentID: &c2.ID}
+	require.NoError(t, storage.CreateComment(ctx, c3))
+	c4 := &model.Comment{PostID: post.ID, ParentCommentID: &root.ID}
+	require.NoError(t, storage.CreateComment(ctx, c4))
+
+	subtree, err := storage.GetCommentSubtree(ctx, root.ID, 2)
+	require.NoError(t, err)
+	expected := []int{root.ID, c1.ID, c2.ID, c4.ID}
+	require.Len(t, subtree, len(expected))
+	for i, c := range subtree {
+		assert.Equal(t, expected[i], c.ID)
+	}
+	assert.Equal(t, 2, subtree[0].ChildCount)
+	assert.Equal(t, 1, subtree[2].ChildCount, "у свернутого узла есть непоказанный ответ")
+	assert.Equal(t, 0, subtree[3].ChildCount)
+
+	_, err = storage.GetCommentSubtree(ctx, -1, 0)
+	assert.Error(t, err)
+}
+
+func TestGetCommentAncestors(t *testing.T) {
+	conf()
+	post := &model.Post{Title: "Пост", AreCommentsAllowed: true}
+	require.NoError(t, storage.CreatePost(ctx, post), "пост не создан")
+
+	root := &model.Comment{PostID: post.ID, Content: "Корневой"}
+	require.NoError(t, storage.CreateComment(ctx, root))
+	c1 := &model.Comment{PostID: post.ID, ParentCommentID: &root.ID, Content: "Уровень 1"}
+	require.NoError(t, storage.CreateComment(ctx, c1))
+	sibling := &model.Comment{PostID: post.ID, ParentCommentID: &root.ID, Content: "Соседняя ветка"}
+	require.NoError(t, storage.CreateComment(ctx, sibling))
+	c2 := &model.Comment{PostID: post.ID, ParentCommentID: &c1.ID, Content: "Уровень 2"}
+	require.NoError(t, storage.CreateComment(ctx, c2))
+	c3 := &model.Comment{PostID: post.ID, ParentCommentID: &c2.ID, Content: "Уровень 3"}
+	require.NoError(t, storage.CreateComment(ctx, c3))
+
+	ancestors, err := storage.GetCommentAncestors(ctx, c3.ID)
+	require.NoError(t, err)
+	require.Len(t, ancestors, 3)
+	assert.Equal(t, root.ID, ancestors[0].ID)
+	assert.Equal(t, c1.ID, ancestors[1].ID)
+	assert.Equal(t, c2.ID, ancestors[2].ID)
+
+	ancestors, err = storage.GetCommentAncestors(ctx, root.ID)
+	require.NoError(t, err)
+	assert.Empty(t, ancestors)
+
+	_, err = storage.GetCommentAncestors(ctx, -1)
+	require.ErrorIs(t, err, store.ErrCommentNotFound)
+}
+
+func TestGetCommentCounts(t *testing.T) {
+	conf()
+	post := &model.Post{Title: "Пост", AreCommentsAllowed: true}
+	require.NoError(t, storage.CreatePost(ctx, post), "пост не создан")
+
+	root := &model.Comment{PostID: post.ID}
+	require.NoError(t, storage.CreateComment(ctx, root))
+	c1 := &model.Comment{PostID: post.ID, ParentCommentID: &root.ID}
+	require.NoError(t, storage.CreateComment(ctx, c1))
+	c2 := &model.Comment{PostID: post.ID, ParentCommentID: &c1.ID}
+	require.NoError(t, storage.CreateComment(ctx, c2))
+	c3 := &model.Comment{PostID: post.ID, ParentCommentID: &c1.ID}
+	require.NoError(t, storage.CreateComment(ctx, c3))
+	c4 := &model.Comment{PostID: post.ID, ParentCommentID: &root.ID}
+	require.NoError(t, storage.CreateComment(ctx, c4))
+
+	counts, err := storage.GetCommentCounts(ctx, []int{root.ID, c1.ID, c2.ID, -1})
+	require.NoError(t, err)
+	assert.Len(t, counts, 3)
+	assert.Equal(t, model.CommentCounts{CommentID: root.ID, ReplyCount: 2, DescendantCount: 4}, counts[root.ID])
+	assert.Equal(t, model.CommentCounts{CommentID: c1.ID, ReplyCount: 2, DescendantCount: 2}, counts[c1.ID])
+	assert.Equal(t, model.CommentCounts{CommentID: c2.ID}, counts[c2.ID])
+}
+
+func TestCommentCountAndLastActivity(t *testing.T) {
+	conf()
+	older := &model.Post{Title: "Старый", Content: "Текст", Author: "Даша", AreCommentsAllowed: true}
+	require.NoError(t, storage.CreatePost(ctx, older), "пост не создан")
+	time.Sleep(time.Millisecond)
+	newer := &model.Post{Title: "Новый", Content: "Текст", Author: "Аня", AreCommentsAllowed: true}
+	require.NoError(t, storage.CreatePost(ctx, newer), "пост не создан")
+	time.Sleep(time.Millisecond)
+
+	root := &model.Comment{PostID: older.ID, Author: "Даша", Content: "Коммент"}
+	require.NoError(t, storage.CreateComment(ctx, root))
+	reply := &model.Comment{PostID: older.ID, Author: "Аня", Content: "Ответ", ParentCommentID: &root.ID}
+	require.NoError(t, storage.CreateComment(ctx, reply))
+
+	post, err := storage.GetPostByID(ctx, older.ID)
+	require.NoError(t, err)
+	assert.Equal(t, 2, post.CommentCount)
+	require.NotNil(t, post.LastCommentAt)
+	assert.Equal(t, reply.CreatedAt.UnixMicro(), post.LastCommentAt.UnixMicro())
+
+	post, err = storage.GetPostByID(ctx, newer.ID)
+	require.NoError(t, err)
+	assert.Zero(t, post.CommentCount)
+	assert.Nil(t, post.LastCommentAt)
+
+	posts, err := storage.GetAllPosts(ctx, model.PostsFilter{Order: model.PostOrderLastActivity})
+	require.NoError(t, err)
+	require.GreaterOrEqual(t, len(posts), 2)
+	assert.Equal(t, older.ID, posts[0].ID, "пост с последним комментарием должен быть первым")
+
+	posts, err = storage.GetAllPosts(ctx, model.PostsFilter{Order: model.PostOrderCreatedAt})
+	require.NoError(t, err)
+	assert.Equal(t, newer.ID, posts[0].ID)
+}
+
+func TestGetCommentsByPosts(t *testing.T) {
+	conf()
+	first := &model.Post{Title: "Первый", Content: "Текст", Author: "Даша", AreCommentsAllowed: true}
+	require.NoError(t, storage.CreatePost(ctx, first), "пост не создан")
+	second := &model.Post{Title: "Второй", Content: "Текст", Author: "Аня", AreCommentsAllowed: true}
+	require.NoError(t, storage.CreatePost(ctx, second), "пост не создан")
+	empty := &model.Post{Title: "Без комментариев", Content: "Текст", Author: "Аня", AreCommentsAllowed: true}
+	require.NoError(t, storage.CreatePost(ctx, empty), "пост не создан")
+
+	var firstIDs []int
+	for i := 0; i < 3; i++ {
+		c := &model.Comment{PostID: first.ID, Author: "Даша", Content: "Коммент"}
+		require.NoError(t, storage.CreateComment(ctx, c))
+		firstIDs = append(firstIDs, c.ID)
+	}
+	secondRoot := &model.Comment{PostID: second.ID, Author: "Аня", Content: "Коммент"}
+	require.NoError(t, storage.CreateComment(ctx, secondRoot))
+	reply := &model.Comment{PostID: second.ID, Author: "Аня", Content: "Ответ", ParentCommentID: &secondRoot.ID}
+	require.NoError(t, storage.CreateComment(ctx, reply))
+
+	comments, amounts, err := storage.GetCommentsByPosts(ctx, []int{first.ID, second.ID, empty.ID}, 2, 1)
+	require.NoError(t, err)
+
+	require.Len(t, comments[first.ID], 2)
+	assert.Equal(t, firstIDs[1], comments[first.ID][0].ID)
+	assert.Equal(t, firstIDs[2], comments[first.ID][1].ID)
+	assert.Equal(t, 3, amounts[first.ID])
+
+	assert.Empty(t, comments[second.ID], "ответы в корневые комментарии не попадают, а страница начинается после единственного корневого")
+	assert.Equal(t, 1, amounts[second.ID])
+
+	assert.NotNil(t, comments[empty.ID])
+	assert.Empty(t, comments[empty.ID])
+	assert.Zero(t, amounts[empty.ID])
+}
+
+func TestGetRevisionsByComments(t *testing.T) {
+	conf()
+	post := &model.Post{Title: "Пост", Content: "Текст", Author: "Даша", AreCommentsAllowed: true}
+	require.NoError(t, storage.CreatePost(ctx, post), "пост не создан")
+
+	edited := &model.Comment{PostID: post.ID, Author: "Даша", Content: "v1"}
+	require.NoError(t, storage.CreateComment(ctx, edited))
+	untouched := &model.Comment{PostID: post.ID, Author: "Даша", Content: "v1"}
+	require.NoError(t, storage.CreateComment(ctx, untouched))
+
+	_, err := storage.EditComment(ctx, edited.ID, "v2")
+	require.NoError(t, err)
+	_, err = storage.EditComment(ctx, edited.ID, "v3")
+	require.NoError(t, err)
+
+	revisions, err := storage.GetRevisionsByComments(ctx, []int{edited.ID, untouched.ID})
+	require.NoError(t, err)
+	require.Len(t, revisions[edited.ID], 2)
+	assert.Equal(t, "v1", revisions[edited.ID][0].Content)
+	assert.Equal(t, "v2", revisions[edited.ID][1].Content)
+	assert.NotNil(t, revisions[untouched.ID])
+	assert.Empty(t, revisions[untouched.ID])
+}
+
+func TestGetRepliesByParents(t *testing.T) {
+	conf()
+	post := &model.Post{Title: "Пост", Content: "Текст", Author: "Даша", AreCommentsAllowed: true}
+	require.NoError(t, storage.CreatePost(ctx, post), "пост не создан")
+
+	root := &model.Comment{PostID: post.ID, Author: "Даша", Content: "Корень"}
+	require.NoError(t, storage.CreateComment(ctx, root))
+	first := &model.Comment{PostID: post.ID, ParentCommentID: &root.ID, Author: "Даша", Content: "Первый"}
+	require.NoError(t, storage.CreateComment(ctx, first))
+	second := &model.Comment{PostID: post.ID, ParentCommentID: &root.ID, Author: "Даша", Content: "Второй"}
+	require.NoError(t, storage.CreateComment(ctx, second))
+	nested := &model.Comment{PostID: post.ID, ParentCommentID: &first.ID, Author: "Даша", Content: "Вложенный"}
+	require.NoError(t, storage.CreateComment(ctx, nested))
+
+	replies, err := storage.GetRepliesByParents(ctx, []int{root.ID, second.ID})
+	require.NoError(t, err)
+	require.Len(t, replies[root.ID], 2, "только прямые ответы")
+	assert.Equal(t, first.ID, replies[root.ID][0].ID)
+	assert.Equal(t, second.ID, replies[root.ID][1].ID)
+	assert.NotNil(t, replies[second.ID])
+	assert.Empty(t, replies[second.ID])
+}
+
+func TestGetAllPosts_CreatedAfter(t *testing.T) {
+	conf()
+	older := &model.Post{Title: "Старый", Content: "Текст", Author: "Даша"}
+	require.NoError(t, storage.CreatePost(ctx, older), "пост не создан")
+	time.Sleep(time.Millisecond)
+	newer := &model.Post{Title: "Новый", Content: "Текст", Author: "Аня"}
+	require.NoError(t, storage.CreatePost(ctx, newer), "пост не создан")
+
+	posts, err := storage.GetAllPosts(ctx, model.PostsFilter{Order: model.PostOrderCreatedAt, CreatedAfter: &older.CreatedAt})
+	require.NoError(t, err)
+	require.Len(t, posts, 1, "граница не включается")
+	assert.Equal(t, newer.ID, posts[0].ID)
+}
@@ -2,11 +2,16 @@ package postgreSQL
 
 import (
 	"OzonTestTask/internal/model"
+	"OzonTestTask/internal/pagination"
+	store "OzonTestTask/internal/storage"
 	"context"
 	"database/sql"
+	"encoding/json"
 	"fmt"
 	"github.com/Masterminds/squirrel"
 	"github.com/jmoiron/sqlx"
+	"github.com/lib/pq"
+	"slices"
 	"time"
 )
 
@@ -42,12 +47,22 @@ func (s *Storage) CreatePost(ctx context.Context, post *model.Post) error {
 	return nil
 }
 
-func (s *Storage) GetAllPosts(ctx context.Context) ([]model.Post, error) {
-	req, args, err := s.squirrel.
-		Select("id", "title", "content", "author", "are_comments_allowed", "created_at").
-		From("posts").
-		OrderBy("created_at DESC").
-		ToSql()
+// GetAllPosts Получение всех постов от новых к старым или по последней активности
+func (s *Storage) GetAllPosts(ctx context.Context, filter model.PostsFilter) ([]model.Post, error) {
+	builder := s.squirrel.
+		Select("id", "title", "content", "author", "are_comments_allowed", "created_at", "comment_count", "last_comment_at").
+		From("posts")
+
+	if filter.CreatedAfter != nil {
+		builder = builder.Where(squirrel.Gt{"created_at": filter.CreatedAfter.UTC()})
+	}
+	if filter.Order == model.PostOrderLastActivity {
+		builder = builder.OrderBy("COALESCE(last_comment_at, created_at) DESC", "id DESC")
+	} else {
+		builder = builder.OrderBy("created_at DESC")
+	}
+
+	req, args, err := builder.ToSql()
 
 	if err != nil {
 		return nil, fmt.Errorf("ошибка при получении постов: %v", err)
@@ -60,9 +75,50 @@ func (s *Storage) GetAllPosts(ctx context.Context) ([]model.Post, error) {
 	return posts, nil
 }
 
+// GetPostsPage Keyset-пагинация ленты постов по (created_at, id) от новых к старым.
+// Запрашиваю на одну строку больше, чтобы понять, есть ли следующая страница
+func (s *Storage) GetPostsPage(ctx context.Context, page pagination.Page) ([]model.Post, bool, error) {
+	builder := s.squirrel.
+		Select("id", "title", "content", "author", "are_comments_allowed", "created_at", "comment_count", "last_comment_at").
+		From("posts").
+		Limit(uint64(page.Limit + 1))
+
+	// лента идет от новых к старым, поэтому "после курсора" - значит старше курсора
+	if page.After != nil {
+		builder = builder.Where("(created_at, id) < (?, ?)", page.After.CreatedAt, page.After.ID)
+	}
+	if page.Before != nil {
+		builder = builder.Where("(created_at, id) > (?, ?)", page.Before.CreatedAt, page.Before.ID)
+	}
+	if page.Backward {
+		builder = builder.OrderBy("created_at ASC", "id ASC")
+	} else {
+		builder = builder.OrderBy("created_at DESC", "id DESC")
+	}
+
+	req, args, err := builder.ToSql()
+	if err != nil {
+		return nil, false, fmt.Errorf("ошибка построения SQL-запроса: %v", err)
+	}
+
+	var posts []model.Post
+	if err = s.db.SelectContext(ctx, &posts, req, args...); err != nil {
+		return nil, false, fmt.Errorf("ошибка при получении страницы постов: %v", err)
+	}
+
+	hasMore := len(posts) > page.Limit
+	if hasMore {
+		posts = posts[:page.Limit]
+	}
+	if page.Backward {
+		slices.Reverse(posts)
+	}
+	return posts, hasMore, nil
+}
+
 func (s *Storage) GetPostByID(ctx context.Context, id int) (*model.Post, error) {
 	req, args, err := s.squirrel.
-		Select("id", "title", "content", "author", "are_comments_allowed", "created_at").
+		Select("id", "title", "content", "author", "are_comments_allowed", "created_at", "comment_count", "last_comment_at").
 		From("posts").
 		Where(squirrel.Eq{"id": id}).
 		ToSql()
@@ -74,13 +130,110 @@ func (s *Storage) GetPostByID(ctx context.Context, id int) (*model.Post, error)
 	var post model.Post
 	if err = s.db.GetContext(ctx, &post, req, args...); err != nil {
 		if err == sql.ErrNoRows {
-			return nil, fmt.Errorf("пост не найден")
+			return nil, store.ErrPostNotFound
 		}
 		return nil, fmt.Errorf("ошибка при получении поста: %v", err)
 	}
 	return &post, nil
 }
 
+// GetPostsByIDs Посты по набору id одним запросом, не найденных id в ответе нет
+func (s *Storage) GetPostsByIDs(ctx context.Context, ids []int) (map[int]model.Post, error) {
+	req, args, err := s.squirrel.
+		Select("id", "title", "content", "author", "are_comments_allowed", "created_at", "comment_count", "last_comment_at").
+		From("posts").
+		Where(squirrel.Eq{"id": ids}).
+		ToSql()
+
+	if err != nil {
+		return nil, fmt.Errorf("ошибка построения SQL-запроса: %v", err)
+	}
+
+	var rows []model.Post
+	if err = s.db.SelectContext(ctx, &rows, req, args...); err != nil {
+		return nil, fmt.Errorf("ошибка при получении постов: %v", err)
+	}
+
+	posts := make(map[int]model.Post, len(rows))
+	for _, post := range rows {
+		posts[post.ID] = post
+	}
+	return posts, nil
+}
+
+// UpdatePost Редактирование заголовка и текста поста
+func (s *Storage) UpdatePost(ctx context.Context, post *model.Post) error {
+	req, args, err := s.squirrel.
+		Update("posts").
+		Set("title", post.Title).
+		Set("content", post.Content).
+		Where(squirrel.Eq{"id": post.ID}).
+		Suffix("RETURNING author, are_comments_allowed, created_at, comment_count, last_comment_at").
+		ToSql()
+
+	if err != nil {
+		return fmt.Errorf("ошибка построения SQL-запроса: %v", err)
+	}
+
+	err = s.db.QueryRowxContext(ctx, req, args...).
+		Scan(&post.Author, &post.AreCommentsAllowed, &post.CreatedAt, &post.CommentCount, &post.LastCommentAt)
+	if err != nil {
+		if err == sql.ErrNoRows {
+			return store.ErrPostNotFound
+		}
+		return fmt.Errorf("ошибка при обновлении поста: %v", err)
+	}
+	return nil
+}
+
+// DeletePost Удаление поста, комментарии к нему удаляются каскадно (ON DELETE CASCADE)
+func (s *Storage) DeletePost(ctx context.Context, id int) error {
+	req, args, err := s.squirrel.
+		Delete("posts").
+		Where(squirrel.Eq{"id": id}).
+		ToSql()
+
+	if err != nil {
+		return fmt.Errorf("ошибка построения SQL-запроса: %v", err)
+	}
+
+	res, err := s.db.ExecContext(ctx, req, args...)
+	if err != nil {
+		return fmt.Errorf("ошибка при удалении поста: %v", err)
+	}
+	affected, err := res.RowsAffected()
+	if err != nil {
+		return fmt.Errorf("ошибка при удалении поста: %v", err)
+	}
+	if affected == 0 {
+		return store.ErrPostNotFound
+	}
+	return nil
+}
+
+// SetCommentsAllowed Разрешение или запрет комментирования поста
+func (s *Storage) SetCommentsAllowed(ctx context.Context, id int, allowed bool) (*model.Post, error) {
+	req, args, err := s.squirrel.
+		Update("posts").
+		Set("are_comments_allowed", allowed).
+		Where(squirrel.Eq{"id": id}).
+		Suffix("RETURNING id, title, content, author, are_comments_allowed, created_at, comment_count, last_comment_at").
+		ToSql()
+
+	if err != nil {
+		return nil, fmt.Errorf("ошибка построения SQL-запроса: %v", err)
+	}
+
+	var post model.Post
+	if err = s.db.QueryRowxContext(ctx, req, args...).StructScan(&post); err != nil {
+		if err == sql.ErrNoRows {
+			return nil, store.ErrPostNotFound
+		}
+		return nil, fmt.Errorf("ошибка при изменении разрешения на комментирование: %v", err)
+	}
+	return &post, nil
+}
+
 func (s *Storage) CreateComment(ctx context.Context, comment *model.Comment) error {
 	tx, err := s.db.BeginTxx(ctx, nil)
 	if err != nil {
@@ -88,15 +241,51 @@ func (s *Storage) CreateComment(ctx context.Context, comment *model.Comment) err
 	}
 	defer tx.Rollback()
 
+	// блокирую строку поста до конца транзакции, чтобы запрет комментирования
+	// не проскочил между проверкой и вставкой. FOR NO KEY UPDATE, а не FOR SHARE,
+	// потому что ниже в этой же транзакции обновляются счетчики поста -
+	// с разделяемой блокировкой две параллельные вставки упирались бы в дедлок
 	var allowed bool
 	commentsAllowedReq, args, err := (s.squirrel.
 		Select("are_comments_allowed").
 		From("posts").
 		Where(squirrel.Eq{"id": comment.PostID})).
+		Suffix("FOR NO KEY UPDATE").
 		ToSql()
 
 	if err = tx.GetContext(ctx, &allowed, commentsAllowedReq, args...); err != nil {
-		return fmt.Errorf("пост не найден: %v", err)
+		if err == sql.ErrNoRows {
+			return store.ErrPostNotFound
+		}
+		return fmt.Errorf("ошибка при получении поста: %v", err)
+	}
+	if !allowed {
+		return store.ErrCommentsDisabled
+	}
+
+	// родитель должен существовать и относиться к тому же посту. FOR KEY SHARE не дает
+	// удалить его до конца транзакции, иначе вставка упала бы на внешнем ключе без понятной ошибки
+	if comment.ParentCommentID != nil {
+		var parentPostID int
+		parentReq, args, err := s.squirrel.
+			Select("post_id").
+			From("comments").
+			Where(squirrel.Eq{"id": *comment.ParentCommentID}).
+			Suffix("FOR KEY SHARE").
+			ToSql()
+
+		if err != nil {
+			return fmt.Errorf("ошибка построения SQL-запроса: %v", err)
+		}
+		if err = tx.GetContext(ctx, &parentPostID, parentReq, args...); err != nil {
+			if err == sql.ErrNoRows {
+				return store.ErrParentCommentNotFound
+			}
+			return fmt.Errorf("ошибка при получении родительского комментария: %v", err)
+		}
+		if parentPostID != comment.PostID {
+			return store.ErrParentCommentNotFound
+		}
 	}
 
 	// вставляю комментарий без path, чтобы получить id коммента и сформировать правильный путь
@@ -128,13 +317,96 @@ func (s *Storage) CreateComment(ctx context.Context, comment *model.Comment) err
 		return fmt.Errorf("ошибка при обновлении path: %v", err)
 	}
 
-	tx.Commit()
+	// денормализованные счетчики поста обновляются в той же транзакции, что и вставка
+	countersReq, args, err := s.squirrel.
+		Update("posts").
+		Set("comment_count", squirrel.Expr("comment_count + 1")).
+		Set("last_comment_at", comment.CreatedAt).
+		Where(squirrel.Eq{"id": comment.PostID}).
+		ToSql()
+
+	if err != nil {
+		return fmt.Errorf("ошибка построения SQL-запроса: %v", err)
+	}
+	if _, err = tx.ExecContext(ctx, countersReq, args...); err != nil {
+		return fmt.Errorf("ошибка при обновлении счетчиков поста: %v", err)
+	}
+
+	// уведомление подписчикам попадает в outbox вместе с комментарием:
+	// либо сохраняется и то и другое, либо ничего. В outbox лежит снимок комментария на момент создания,
+	// чтобы правка или удаление до отправки не меняли то, что получат подписчики
+	payload, err := json.Marshal(comment)
+	if err != nil {
+		return fmt.Errorf("не удалось сериализовать комментарий для outbox: %v", err)
+	}
+	outboxReq, args, err := s.squirrel.
+		Insert("comment_outbox").
+		Columns("comment_id", "payload").
+		Values(comment.ID, payload).
+		ToSql()
+
+	if err != nil {
+		return fmt.Errorf("ошибка построения SQL-запроса: %v", err)
+	}
+	if _, err = tx.ExecContext(ctx, outboxReq, args...); err != nil {
+		return fmt.Errorf("ошибка при записи уведомления в outbox: %v", err)
+	}
+
+	if err = tx.Commit(); err != nil {
+		return fmt.Errorf("ошибка фиксации транзакции: %v", err)
+	}
 	return nil
 }
 
+// GetCommentByID Получение комментария по ID, удаленные тоже возвращаются - как и в ветке ответов
+func (s *Storage) GetCommentByID(ctx context.Context, id int) (*model.Comment, error) {
+	req, args, err := s.squirrel.
+		Select("id", "post_id", "author", "content", "parent_comment_id", "path::text AS path", "created_at", "edited_at", "deleted").
+		From("comments").
+		Where(squirrel.Eq{"id": id}).
+		ToSql()
+
+	if err != nil {
+		return nil, fmt.Errorf("ошибка построения SQL-запроса: %v", err)
+	}
+
+	var comment model.Comment
+	if err = s.db.GetContext(ctx, &comment, req, args...); err != nil {
+		if err == sql.ErrNoRows {
+			return nil, store.ErrCommentNotFound
+		}
+		return nil, fmt.Errorf("ошибка при получении комментария: %v", err)
+	}
+	return &comment, nil
+}
+
+// GetCommentsByIDs Комментарии по набору id одним запросом, не найденных id в ответе нет
+func (s *Storage) GetCommentsByIDs(ctx context.Context, ids []int) (map[int]model.Comment, error) {
+	req, args, err := s.squirrel.
+		Select("id", "post_id", "author", "content", "parent_comment_id", "path::text AS path", "created_at", "edited_at", "deleted").
+		From("comments").
+		Where(squirrel.Eq{"id": ids}).
+		ToSql()
+
+	if err != nil {
+		return nil, fmt.Errorf("ошибка построения SQL-запроса: %v", err)
+	}
+
+	var rows []model.Comment
+	if err = s.db.SelectContext(ctx, &rows, req, args...); err != nil {
+		return nil, fmt.Errorf("ошибка при получении комментариев: %v", err)
+	}
+
+	comments := make(map[int]model.Comment, len(rows))
+	for _, comment := range rows {
+		comments[comment.ID] = comment
+	}
+	return comments, nil
+}
+
 func (s *Storage) GetCommentsByPost(ctx context.Context, postID, limit, offset int) ([]model.Comment, int, error) {
 	req, args, err := s.squirrel.
-		Select("id", "post_id", "author", "content", "parent_comment_id", "path::text AS path", "created_at").
+		Select("id", "post_id", "author", "content", "parent_comment_id", "path::text AS path", "created_at", "edited_at", "deleted").
 		From("comments").
 		Where(squirrel.Eq{"post_id": postID}).
 		Where("parent_comment_id IS NULL").
@@ -167,20 +439,521 @@ func (s *Storage) GetCommentsByPost(ctx context.Context, postID, limit, offset i
 	return comments, amount, nil
 }
 
-func (s *Storage) GetReplies(ctx context.Context, parentID int) ([]model.Comment, error) {
+// GetCommentsByPosts Страница корневых комментариев сразу для нескольких постов одним запросом
+// (для даталоадера). Нумерую комментарии внутри каждого поста оконной функцией и беру нужный отрезок,
+// первая строка поста приходит всегда - по ней узнаю количество корневых комментариев, даже если страница пустая
+func (s *Storage) GetCommentsByPosts(ctx context.Context, postIDs []int, limit, offset int) (map[int][]model.Comment, map[int]int, error) {
+	sqlStr := `
+		WITH ranked AS (
+			SELECT id, post_id, author, content, parent_comment_id, path::text AS path, created_at, edited_at, deleted,
+				ROW_NUMBER() OVER (PARTITION BY post_id ORDER BY created_at, id) AS rn,
+				COUNT(*) OVER (PARTITION BY post_id) AS total
+			FROM comments
+			WHERE post_id = ANY($1) AND parent_comment_id IS NULL
+		)
+		SELECT id, post_id, author, content, parent_comment_id, path, created_at, edited_at, deleted, rn, total
+		FROM ranked
+		WHERE rn = 1 OR (rn > $2 AND rn <= $2 + $3)
+		ORDER BY post_id, rn`
+
+	var rows []struct {
+		model.Comment
+		RowNumber int `db:"rn"`
+		Total     int `db:"total"`
+	}
+	if err := s.db.SelectContext(ctx, &rows, sqlStr, pq.Array(postIDs), offset, limit); err != nil {
+		return nil, nil, fmt.Errorf("ошибка при получении корневых комментариев: %v", err)
+	}
+
+	comments := make(map[int][]model.Comment, len(postIDs))
+	amounts := make(map[int]int, len(postIDs))
+	for _, id := range postIDs {
+		comments[id] = []model.Comment{}
+	}
+	for _, row := range rows {
+		amounts[row.PostID] = row.Total
+		if row.RowNumber > offset && row.RowNumber <= offset+limit {
+			comments[row.PostID] = append(comments[row.PostID], row.Comment)
+		}
+	}
+	return comments, amounts, nil
+}
+
+// GetCommentsPage Keyset-пагинация корневых комментариев по (created_at, id) от старых к новым.
+// В отличие от LIMIT/OFFSET страницы не съезжают при добавлении новых комментариев
+func (s *Storage) GetCommentsPage(ctx context.Context, postID int, page pagination.Page) ([]model.Comment, bool, error) {
+	builder := s.squirrel.
+		Select("id", "post_id", "author", "content", "parent_comment_id", "path::text AS path", "created_at", "edited_at", "deleted").
+		From("comments").
+		Where(squirrel.Eq{"post_id": postID}).
+		Where("parent_comment_id IS NULL").
+		Limit(uint64(page.Limit + 1))
+
+	if page.After != nil {
+		builder = builder.Where("(created_at, id) > (?, ?)", page.After.CreatedAt, page.After.ID)
+	}
+	if page.Before != nil {
+		builder = builder.Where("(created_at, id) < (?, ?)", page.Before.CreatedAt, page.Before.ID)
+	}
+	if page.Backward {
+		builder = builder.OrderBy("created_at DESC", "id DESC")
+	} else {
+		builder = builder.OrderBy("created_at ASC", "id ASC")
+	}
+
+	req, args, err := builder.ToSql()
+	if err != nil {
+		return nil, false, fmt.Errorf("ошибка формирования запроса на получение корневых комментариев: %v", err)
+	}
+
+	var comments []model.Comment
+	if err = s.db.SelectContext(ctx, &comments, req, args...); err != nil {
+		return nil, false, fmt.Errorf("ошибка при получении корневых комментариев: %v", err)
+	}
+
+	hasMore := len(comments) > page.Limit
+	if hasMore {
+		comments = comments[:page.Limit]
+	}
+	if page.Backward {
+		slices.Reverse(comments)
+	}
+	return comments, hasMore, nil
+}
+
+func (s *Storage) GetReplies(ctx context.Context, parentID int, page pagination.RepliesPage) ([]model.Comment, error) {
 	// не использую здесь squirrel, потому что работа с ltree
-	// более читаема и удобна в написании с raw sql-запросом
+	// более читаема и удобна в написании с raw sql-запросом.
+	// siblings - страница прямых ответов (по id после AfterID), дальше берутся их ветки
+	// с ограничением глубины через nlevel. NULL в LIMIT и в maxDepth означает "без ограничений".
+	// метки ltree сравниваются как текст ("10" < "9"), поэтому сортирую по path как по массиву чисел:
+	// обход в глубину с ответами по возрастанию id, тот же ключ, что и у страницы прямых ответов
 	sqlStr := `
-		SELECT c2.id, c2.post_id, c2.author, c2.content, c2.parent_comment_id, c2.path::text, c2.created_at
+		WITH siblings AS (
+			SELECT path
+			FROM comments
+			WHERE parent_comment_id = $1 AND id > $2
+			ORDER BY id
+			LIMIT $3
+		)
+		SELECT c2.id, c2.post_id, c2.author, c2.content, c2.parent_comment_id, c2.path::text, c2.created_at, c2.edited_at, c2.deleted
 		FROM comments AS c1
-		JOIN comments AS c2 ON c2.path <@ c1.path AND c2.id != c1.id
+		JOIN siblings AS s ON TRUE
+		JOIN comments AS c2 ON c2.path <@ s.path
 		WHERE c1.id = $1
-		ORDER BY c2.path`
+			AND ($4::int IS NULL OR nlevel(c2.path) - nlevel(c1.path) <= $4)
+		ORDER BY string_to_array(c2.path::text, '.')::int[]`
+
+	var limit, maxDepth *int
+	if page.First > 0 {
+		limit = &page.First
+	}
+	if page.MaxDepth > 0 {
+		maxDepth = &page.MaxDepth
+	}
 
 	var comments []model.Comment
-	if err := s.db.SelectContext(ctx, &comments, sqlStr, parentID); err != nil {
+	if err := s.db.SelectContext(ctx, &comments, sqlStr, parentID, page.AfterID, limit, maxDepth); err != nil {
 		return nil, fmt.Errorf("ошибка при получении вложенных комментариев: %v", err)
 	}
 
+	// пустой результат - либо у комментария нет ответов, либо его самого нет
+	if len(comments) == 0 {
+		exists, err := s.commentExists(ctx, parentID)
+		if err != nil {
+			return nil, err
+		}
+		if !exists {
+			return nil, store.ErrCommentNotFound
+		}
+	}
+	return comments, nil
+}
+
+func (s *Storage) commentExists(ctx context.Context, id int) (bool, error) {
+	var exists bool
+	if err := s.db.GetContext(ctx, &exists, "SELECT EXISTS (SELECT 1 FROM comments WHERE id = $1)", id); err != nil {
+		return false, fmt.Errorf("ошибка при проверке комментария: %v", err)
+	}
+	return exists, nil
+}
+
+// GetRepliesByParents Прямые ответы сразу для нескольких комментариев по возрастанию id (для даталоадера)
+func (s *Storage) GetRepliesByParents(ctx context.Context, parentIDs []int) (map[int][]model.Comment, error) {
+	req, args, err := s.squirrel.
+		Select("id", "post_id", "author", "content", "parent_comment_id", "path::text AS path", "created_at", "edited_at", "deleted").
+		From("comments").
+		Where(squirrel.Eq{"parent_comment_id": parentIDs}).
+		OrderBy("id ASC").
+		ToSql()
+
+	if err != nil {
+		return nil, fmt.Errorf("ошибка построения SQL-запроса: %v", err)
+	}
+
+	var rows []model.Comment
+	if err = s.db.SelectContext(ctx, &rows, req, args...); err != nil {
+		return nil, fmt.Errorf("ошибка при получении ответов на комментарии: %v", err)
+	}
+
+	replies := make(map[int][]model.Comment, len(parentIDs))
+	for _, id := range parentIDs {
+		replies[id] = []model.Comment{}
+	}
+	for _, row := range rows {
+		replies[*row.ParentCommentID] = append(replies[*row.ParentCommentID], row)
+	}
+	return replies, nil
+}
+
+// EditComment Редактирование текста комментария с сохранением предыдущей версии в comment_revisions
+func (s *Storage) EditComment(ctx context.Context, id int, content string) (*model.Comment, error) {
+	tx, err := s.db.BeginTxx(ctx, nil)
+	if err != nil {
+		return nil, fmt.Errorf("ошибка начала транзакции: %v", err)
+	}
+	defer tx.Rollback()
+
+	// FOR UPDATE - чтобы параллельные правки одного комментария не потеряли ревизию
+	req, args, err := s.squirrel.
+		Select("id", "post_id", "author", "content", "parent_comment_id", "path::text AS path", "created_at", "edited_at", "deleted").
+		From("comments").
+		Where(squirrel.Eq{"id": id}).
+		Suffix("FOR UPDATE").
+		ToSql()
+
+	if err != nil {
+		return nil, fmt.Errorf("ошибка построения SQL-запроса: %v", err)
+	}
+
+	var comment model.Comment
+	if err = tx.GetContext(ctx, &comment, req, args...); err != nil {
+		if err == sql.ErrNoRows {
+			return nil, store.ErrCommentNotFound
+		}
+		return nil, fmt.Errorf("ошибка при получении комментария: %v", err)
+	}
+	if comment.Deleted {
+		return nil, store.ErrCommentDeleted
+	}
+
+	// ревизия хранит текст и время, с которого этот текст был актуален
+	revisionCreatedAt := comment.CreatedAt
+	if comment.EditedAt != nil {
+		revisionCreatedAt = *comment.EditedAt
+	}
+	req, args, err = s.squirrel.
+		Insert("comment_revisions").
+		Columns("comment_id", "content", "created_at").
+		Values(comment.ID, comment.Content, revisionCreatedAt).
+		ToSql()
+
+	if err != nil {
+		return nil, fmt.Errorf("ошибка построения SQL-запроса: %v", err)
+	}
+	if _, err = tx.ExecContext(ctx, req, args...); err != nil {
+		return nil, fmt.Errorf("ошибка при сохранении ревизии комментария: %v", err)
+	}
+
+	editedAt := time.Now().UTC()
+	req, args, err = s.squirrel.
+		Update("comments").
+		Set("content", content).
+		Set("edited_at", editedAt).
+		Where(squirrel.Eq{"id": id}).
+		ToSql()
+
+	if err != nil {
+		return nil, fmt.Errorf("ошибка построения SQL-запроса: %v", err)
+	}
+	if _, err = tx.ExecContext(ctx, req, args...); err != nil {
+		return nil, fmt.Errorf("ошибка при обновлении комментария: %v", err)
+	}
+
+	if err = tx.Commit(); err != nil {
+		return nil, fmt.Errorf("ошибка фиксации транзакции: %v", err)
+	}
+
+	comment.Content = content
+	comment.EditedAt = &editedAt
+	return &comment, nil
+}
+
+// GetCommentRevisions Получение истории изменений комментария от старых версий к новым
+func (s *Storage) GetCommentRevisions(ctx context.Context, commentID int) ([]model.CommentRevision, error) {
+	req, args, err := s.squirrel.
+		Select("id", "comment_id", "content", "created_at").
+		From("comment_revisions").
+		Where(squirrel.Eq{"comment_id": commentID}).
+		OrderBy("id ASC").
+		ToSql()
+
+	if err != nil {
+		return nil, fmt.Errorf("ошибка построения SQL-запроса: %v", err)
+	}
+
+	var revisions []model.CommentRevision
+	if err = s.db.SelectContext(ctx, &revisions, req, args...); err != nil {
+		return nil, fmt.Errorf("ошибка при получении истории изменений комментария: %v", err)
+	}
+	return revisions, nil
+}
+
+// GetRevisionsByComments История изменений сразу для нескольких комментариев (для даталоадера)
+func (s *Storage) GetRevisionsByComments(ctx context.Context, commentIDs []int) (map[int][]model.CommentRevision, error) {
+	req, args, err := s.squirrel.
+		Select("id", "comment_id", "content", "created_at").
+		From("comment_revisions").
+		Where(squirrel.Eq{"comment_id": commentIDs}).
+		OrderBy("id ASC").
+		ToSql()
+
+	if err != nil {
+		return nil, fmt.Errorf("ошибка построения SQL-запроса: %v", err)
+	}
+
+	var rows []model.CommentRevision
+	if err = s.db.SelectContext(ctx, &rows, req, args...); err != nil {
+		return nil, fmt.Errorf("ошибка при получении истории изменений комментариев: %v", err)
+	}
+
+	revisions := make(map[int][]model.CommentRevision, len(commentIDs))
+	for _, id := range commentIDs {
+		revisions[id] = []model.CommentRevision{}
+	}
+	for _, row := range rows {
+		revisions[row.CommentID] = append(revisions[row.CommentID], row)
+	}
+	return revisions, nil
+}
+
+// DeleteComment Мягкое удаление комментария: текст и история изменений стираются,
+// а сама строка и ее path остаются, чтобы не потерять ветку ответов
+func (s *Storage) DeleteComment(ctx context.Context, id int) (*model.Comment, error) {
+	tx, err := s.db.BeginTxx(ctx, nil)
+	if err != nil {
+		return nil, fmt.Errorf("ошибка начала транзакции: %v", err)
+	}
+	defer tx.Rollback()
+
+	req, args, err := s.squirrel.
+		Update("comments").
+		Set("content", "").
+		Set("deleted", true).
+		Where(squirrel.Eq{"id": id}).
+		Suffix("RETURNING id, post_id, author, content, parent_comment_id, path::text AS path, created_at, edited_at, deleted").
+		ToSql()
+
+	if err != nil {
+		return nil, fmt.Errorf("ошибка построения SQL-запроса: %v", err)
+	}
+
+	var comment model.Comment
+	if err = tx.QueryRowxContext(ctx, req, args...).StructScan(&comment); err != nil {
+		if err == sql.ErrNoRows {
+			return nil, store.ErrCommentNotFound
+		}
+		return nil, fmt.Errorf("ошибка при удалении комментария: %v", err)
+	}
+
+	req, args, err = s.squirrel.
+		Delete("comment_revisions").
+		Where(squirrel.Eq{"comment_id": id}).
+		ToSql()
+
+	if err != nil {
+		return nil, fmt.Errorf("ошибка построения SQL-запроса: %v", err)
+	}
+	if _, err = tx.ExecContext(ctx, req, args...); err != nil {
+		return nil, fmt.Errorf("ошибка при удалении истории изменений комментария: %v", err)
+	}
+
+	if err = tx.Commit(); err != nil {
+		return nil, fmt.Errorf("ошибка фиксации транзакции: %v", err)
+	}
+	return &comment, nil
+}
+
+// GetCommentSubtree Комментарий rootID и его ветка до глубины maxDepth (0 - без ограничений)
+// в порядке обхода в глубину с ответами по возрастанию id, как в GetReplies.
+// Для каждого комментария считается количество прямых ответов, чтобы показать свернутые ветки
+func (s *Storage) GetCommentSubtree(ctx context.Context, rootID int, maxDepth int) ([]model.Comment, error) {
+	sqlStr := `
+		SELECT c2.id, c2.post_id, c2.author, c2.content, c2.parent_comment_id, c2.path::text, c2.created_at, c2.edited_at, c2.deleted,
+			(SELECT COUNT(*) FROM comments AS c3 WHERE c3.parent_comment_id = c2.id) AS child_count
+		FROM comments AS c1
+		JOIN comments AS c2 ON c2.path <@ c1.path
+		WHERE c1.id = $1
+			AND ($2::int IS NULL OR nlevel(c2.path) - nlevel(c1.path) <= $2)
+		ORDER BY string_to_array(c2.path::text, '.')::int[]`
+
+	var depth *int
+	if maxDepth > 0 {
+		depth = &maxDepth
+	}
+
+	var comments []model.Comment
+	if err := s.db.SelectContext(ctx, &comments, sqlStr, rootID, depth); err != nil {
+		return nil, fmt.Errorf("ошибка при получении дерева комментариев: %v", err)
+	}
 	return comments, nil
 }
+
+// GetCommentAncestors Предки комментария от корневого до родителя: все комментарии,
+// чей path является префиксом path комментария (оператор @> использует GiST-индекс по path).
+// Сам комментарий тоже попадает в выборку последним - так без отдельного запроса видно, что он существует
+func (s *Storage) GetCommentAncestors(ctx context.Context, id int) ([]model.Comment, error) {
+	sqlStr := `
+		SELECT a.id, a.post_id, a.author, a.content, a.parent_comment_id, a.path::text, a.created_at, a.edited_at, a.deleted
+		FROM comments AS c
+		JOIN comments AS a ON a.path @> c.path
+		WHERE c.id = $1
+		ORDER BY nlevel(a.path)`
+
+	var chain []model.Comment
+	if err := s.db.SelectContext(ctx, &chain, sqlStr, id); err != nil {
+		return nil, fmt.Errorf("ошибка при получении родительских комментариев: %v", err)
+	}
+	if len(chain) == 0 {
+		return nil, store.ErrCommentNotFound
+	}
+	return chain[:len(chain)-1], nil
+}
+
+// GetCommentCounts Количество прямых ответов (по parent_comment_id) и всех потомков (по ltree) одним запросом
+// для всего набора комментариев
+func (s *Storage) GetCommentCounts(ctx context.Context, ids []int) (map[int]model.CommentCounts, error) {
+	sqlStr := `
+		SELECT c.id AS comment_id,
+			(SELECT COUNT(*) FROM comments AS r WHERE r.parent_comment_id = c.id) AS reply_count,
+			(SELECT COUNT(*) FROM comments AS d WHERE d.path <@ c.path AND d.id != c.id) AS descendant_count
+		FROM comments AS c
+		WHERE c.id = ANY($1)`
+
+	var rows []model.CommentCounts
+	if err := s.db.SelectContext(ctx, &rows, sqlStr, pq.Array(ids)); err != nil {
+		return nil, fmt.Errorf("ошибка при подсчете ответов на комментарии: %v", err)
+	}
+
+	counts := make(map[int]model.CommentCounts, len(rows))
+	for _, row := range rows {
+		counts[row.CommentID] = row
+	}
+	return counts, nil
+}
+
+// DeliverNotifications Отправка пачки неотправленных уведомлений из outbox от старых к новым.
+// Строки захватываются FOR UPDATE SKIP LOCKED, поэтому несколько экземпляров приложения забирают разные пачки
+// и не отправляют одно уведомление дважды. Отправленные до первой ошибки publish помечаются в той же транзакции,
+// остальные остаются в outbox; ошибка publish возвращается как есть
+func (s *Storage) DeliverNotifications(ctx context.Context, limit int, publish func(message model.OutboxMessage) error) (int, error) {
+	tx, err := s.db.BeginTxx(ctx, nil)
+	if err != nil {
+		return 0, fmt.Errorf("ошибка начала транзакции: %v", err)
+	}
+	defer tx.Rollback()
+
+	req, args, err := s.squirrel.
+		Select("id", "payload").
+		From("comment_outbox").
+		Where(squirrel.Eq{"delivered_at": nil}).
+		OrderBy("id").
+		Limit(uint64(limit)).
+		Suffix("FOR UPDATE SKIP LOCKED").
+		ToSql()
+
+	if err != nil {
+		return 0, fmt.Errorf("ошибка построения SQL-запроса: %v", err)
+	}
+
+	var rows []struct {
+		ID      int    `db:"id"`
+		Payload []byte `db:"payload"`
+	}
+	if err = tx.SelectContext(ctx, &rows, req, args...); err != nil {
+		return 0, fmt.Errorf("ошибка при получении уведомлений из outbox: %v", err)
+	}
+
+	delivered := make([]int, 0, len(rows))
+	var publishErr error
+	for _, row := range rows {
+		message := model.OutboxMessage{ID: row.ID}
+		if err = json.Unmarshal(row.Payload, &message.Comment); err != nil {
+			publishErr = fmt.Errorf("некорректный снимок комментария в outbox %d: %v", row.ID, err)
+			break
+		}
+		if publishErr = publish(message); publishErr != nil {
+			break
+		}
+		delivered = append(delivered, row.ID)
+	}
+	if len(delivered) == 0 {
+		return 0, publishErr
+	}
+
+	markReq, args, err := s.squirrel.
+		Update("comment_outbox").
+		Set("delivered_at", time.Now().UTC()).
+		Where(squirrel.Eq{"id": delivered}).
+		ToSql()
+
+	if err != nil {
+		return 0, fmt.Errorf("ошибка построения SQL-запроса: %v", err)
+	}
+	if _, err = tx.ExecContext(ctx, markReq, args...); err != nil {
+		return 0, fmt.Errorf("ошибка при пометке уведомлений outbox: %v", err)
+	}
+	if err = tx.Commit(); err != nil {
+		return 0, fmt.Errorf("ошибка фиксации транзакции: %v", err)
+	}
+	return len(delivered), publishErr
+}
+
+// PurgeDeliveredNotifications Удаление уведомлений, отправленных раньше before
+func (s *Storage) PurgeDeliveredNotifications(ctx context.Context, before time.Time) (int, error) {
+	req, args, err := s.squirrel.
+		Delete("comment_outbox").
+		Where(squirrel.Lt{"delivered_at": before}).
+		ToSql()
+
+	if err != nil {
+		return 0, fmt.Errorf("ошибка построения SQL-запроса: %v", err)
+	}
+	result, err := s.db.ExecContext(ctx, req, args...)
+	if err != nil {
+		return 0, fmt.Errorf("ошибка при очистке outbox: %v", err)
+	}
+	purged, err := result.RowsAffected()
+	if err != nil {
+		return 0, fmt.Errorf("ошибка при очистке outbox: %v", err)
+	}
+	return int(purged), nil
+}
+
+// GetCommentSnapshot Комментарий в том виде, в каком он был создан, - снимок из outbox.
+// Нужен подписчикам, которым в уведомлении пришел только id
+func (s *Storage) GetCommentSnapshot(ctx context.Context, commentID int) (*model.Comment, error) {
+	req, args, err := s.squirrel.
+		Select("payload").
+		From("comment_outbox").
+		Where(squirrel.Eq{"comment_id": commentID}).
+		ToSql()
+
+	if err != nil {
+		return nil, fmt.Errorf("ошибка построения SQL-запроса: %v", err)
+	}
+
+	var payload []byte
+	if err = s.db.GetContext(ctx, &payload, req, args...); err != nil {
+		if err == sql.ErrNoRows {
+			return nil, store.ErrCommentNotFound
+		}
+		return nil, fmt.Errorf("ошибка при получении снимка комментария: %v", err)
+	}
+
+	var comment model.Comment
+	if err = json.Unmarshal(payload, &comment); err != nil {
+		return nil, fmt.Errorf("некорректный снимок комментария %d в outbox: %v", commentID, err)
+	}
+	return &comment, nil
+}
@@ -2,6 +2,8 @@ package postgreSQL
 
 import (
 	"OzonTestTask/internal/model"
+	"OzonTestTask/internal/pagination"
+	store "OzonTestTask/internal/storage"
 	"context"
 	"fmt"
 	"github.com/jmoiron/sqlx"
@@ -10,6 +12,7 @@ import (
 	"log"
 	"os"
 	"testing"
+	"time"
 )
 
 var (
@@ -60,7 +63,7 @@ func TestGetAllPosts(t *testing.T) {
 		Author:  "Дарья",
 	}
 	require.NoError(t, storage.CreatePost(ctx, post), "пост не создан")
-	posts, err := storage.GetAllPosts(ctx)
+	posts, err := storage.GetAllPosts(ctx, model.PostsFilter{Order: model.PostOrderCreatedAt})
 	require.NoError(t, err, "не удалось получить посты")
 	assert.NotEmpty(t, posts, "должен быть хотя бы один пост")
 }
@@ -94,7 +97,7 @@ func TestCreateAndGetComments(t *testing.T) {
 	assert.Equal(t, 1, total)
 	assert.Equal(t, root.ID, rootComments[0].ID)
 
-	replies, err := storage.GetReplies(ctx, root.ID)
+	replies, err := storage.GetReplies(ctx, root.ID, pagination.RepliesPage{})
 	require.NoError(t, err)
 	assert.Len(t, replies, 1)
 	assert.Equal(t, reply.ID, replies[0].ID)
@@ -116,6 +119,23 @@ func TestCreateComment_WrongPostID(t *testing.T) {
 	assert.Error(t, err)
 }
 
+func TestCreateComment_WrongParent(t *testing.T) {
+	post := &model.Post{Title: "Пост", Content: "Текст", Author: "Даша", AreCommentsAllowed: true}
+	require.NoError(t, storage.CreatePost(ctx, post), "пост не создан")
+	other := &model.Post{Title: "Другой пост", Content: "Текст", Author: "Даша", AreCommentsAllowed: true}
+	require.NoError(t, storage.CreatePost(ctx, other), "пост не создан")
+	foreign := &model.Comment{PostID: other.ID, Author: "Даша", Content: "Чужой"}
+	require.NoError(t, storage.CreateComment(ctx, foreign))
+
+	missingID := -1
+	err := storage.CreateComment(ctx, &model.Comment{PostID: post.ID, ParentCommentID: &missingID, Author: "Даша", Content: "Ответ"})
+	require.ErrorIs(t, err, store.ErrParentCommentNotFound)
+
+	// ответ на комментарий другого поста
+	err = storage.CreateComment(ctx, &model.Comment{PostID: post.ID, ParentCommentID: &foreign.ID, Author: "Даша", Content: "Ответ"})
+	require.ErrorIs(t, err, store.ErrParentCommentNotFound)
+}
+
 func TestGetRepliesDeep(t *testing.T) {
 	post := &model.Post{
 		Title:              "Пост",
@@ -136,7 +156,7 @@ func TestGetRepliesDeep(t *testing.T) {
 		expectedIDs = append(expectedIDs, c.ID)
 	}
 
-	replies, err := storage.GetReplies(ctx, expectedIDs[0])
+	replies, err := storage.GetReplies(ctx, expectedIDs[0], pagination.RepliesPage{})
 	require.NoError(t, err)
 	assert.Len(t, replies, len(expectedIDs)-1)
 
@@ -219,7 +239,7 @@ func TestGetRepliesDeepAndBranching(t *testing.T) {
 		require.NoError(t, storage.CreateComment(ctx, b), "комментарий не создан")
 	}
 
-	replies, err := storage.GetReplies(ctx, root.ID)
+	replies, err := storage.GetReplies(ctx, root.ID, pagination.RepliesPage{})
 	require.NoError(t, err, "ошибка при получении вложенных комментариев")
 
 	expectedCount := 8
@@ -249,3 +269,676 @@ func TestGetRepliesDeepAndBranching(t *testing.T) {
 			"неверный порядок комментариев на позиции %d", i)
 	}
 }
+
+func TestUpdatePost(t *testing.T) {
+	post := &model.Post{Title: "Старый", Content: "Текст", Author: "Даша", AreCommentsAllowed: true}
+	require.NoError(t, storage.CreatePost(ctx, post), "пост не создан")
+
+	update := &model.Post{ID: post.ID, Title: "Новый", Content: "Исправленный текст"}
+	require.NoError(t, storage.UpdatePost(ctx, update))
+	assert.Equal(t, post.Author, update.Author)
+	assert.True(t, update.AreCommentsAllowed)
+
+	updated, err := storage.GetPostByID(ctx, post.ID)
+	require.NoError(t, err)
+	assert.Equal(t, "Новый", updated.Title)
+	assert.Equal(t, "Исправленный текст", updated.Content)
+}
+
+func TestUpdatePost_WrongID(t *testing.T) {
+	err := storage.UpdatePost(ctx, &model.Post{ID: -1, Title: "Пост", Content: "Текст"})
+	assert.Error(t, err)
+}
+
+func TestDeletePost(t *testing.T) {
+	post := &model.Post{Title: "Удаляемый", Content: "Текст", Author: "Даша", AreCommentsAllowed: true}
+	require.NoError(t, storage.CreatePost(ctx, post), "пост не создан")
+
+	root := &model.Comment{PostID: post.ID, Author: "Анна", Content: "Корневой"}
+	require.NoError(t, storage.CreateComment(ctx, root))
+	reply := &model.Comment{PostID: post.ID, ParentCommentID: &root.ID, Author: "Олег", Content: "Ответ"}
+	require.NoError(t, storage.CreateComment(ctx, reply))
+
+	require.NoError(t, storage.DeletePost(ctx, post.ID))
+
+	_, err := storage.GetPostByID(ctx, post.ID)
+	assert.Error(t, err)
+
+	var amount int
+	require.NoError(t, db.Get(&amount, "SELECT COUNT(*) FROM comments WHERE post_id = $1", post.ID))
+	assert.Zero(t, amount, "комментарии должны удаляться каскадно")
+}
+
+func TestDeletePost_WrongID(t *testing.T) {
+	assert.Error(t, storage.DeletePost(ctx, -1))
+}
+
+func TestSetCommentsAllowed(t *testing.T) {
+	post := &model.Post{Title: "Пост", Content: "Текст", Author: "Даша", AreCommentsAllowed: true}
+	require.NoError(t, storage.CreatePost(ctx, post), "пост не создан")
+
+	locked, err := storage.SetCommentsAllowed(ctx, post.ID, false)
+	require.NoError(t, err)
+	assert.False(t, locked.AreCommentsAllowed)
+	assert.Equal(t, post.Title, locked.Title)
+
+	err = storage.CreateComment(ctx, &model.Comment{PostID: post.ID, Author: "Анна", Content: "Текст"})
+	assert.Error(t, err, "в закрытый пост нельзя добавить комментарий")
+
+	_, err = storage.SetCommentsAllowed(ctx, post.ID, true)
+	require.NoError(t, err)
+	assert.NoError(t, storage.CreateComment(ctx, &model.Comment{PostID: post.ID, Author: "Анна", Content: "Текст"}))
+}
+
+func TestSetCommentsAllowed_WrongID(t *testing.T) {
+	post, err := storage.SetCommentsAllowed(ctx, -1, false)
+	assert.Nil(t, post)
+	assert.Error(t, err)
+}
+
+func TestEditComment(t *testing.T) {
+	post := &model.Post{Title: "Пост", Content: "Текст", Author: "Даша", AreCommentsAllowed: true}
+	require.NoError(t, storage.CreatePost(ctx, post), "пост не создан")
+	comment := &model.Comment{PostID: post.ID, Author: "Анна", Content: "Опечтака"}
+	require.NoError(t, storage.CreateComment(ctx, comment), "комментарий не создан")
+
+	edited, err := storage.EditComment(ctx, comment.ID, "Опечатка")
+	require.NoError(t, err)
+	assert.Equal(t, "Опечатка", edited.Content)
+	assert.Equal(t, comment.Path, edited.Path)
+	require.NotNil(t, edited.EditedAt)
+
+	_, err = storage.EditComment(ctx, comment.ID, "Опечатка исправлена")
+	require.NoError(t, err)
+
+	revisions, err := storage.GetCommentRevisions(ctx, comment.ID)
+	require.NoError(t, err)
+	require.Len(t, revisions, 2)
+	assert.Equal(t, "Опечтака", revisions[0].Content)
+	assert.Equal(t, "Опечатка", revisions[1].Content)
+
+	rootComments, _, err := storage.GetCommentsByPost(ctx, post.ID, 10, 0)
+	require.NoError(t, err)
+	assert.Equal(t, "Опечатка исправлена", rootComments[0].Content)
+	assert.NotNil(t, rootComments[0].EditedAt)
+}
+
+func TestEditComment_WrongID(t *testing.T) {
+	comment, err := storage.EditComment(ctx, -1, "Текст")
+	assert.Nil(t, comment)
+	assert.Error(t, err)
+}
+
+func TestDeleteComment(t *testing.T) {
+	post := &model.Post{Title: "Пост", Content: "Текст", Author: "Даша", AreCommentsAllowed: true}
+	require.NoError(t, storage.CreatePost(ctx, post), "пост не создан")
+
+	root := &model.Comment{PostID: post.ID, Author: "Анна", Content: "Корневой"}
+	require.NoError(t, storage.CreateComment(ctx, root))
+	c1 := &model.Comment{PostID: post.ID, ParentCommentID: &root.ID, Author: "Олег", Content: "Удаляемый"}
+	require.NoError(t, storage.CreateComment(ctx, c1))
+	c2 := &model.Comment{PostID: post.ID, ParentCommentID: &c1.ID, Author: "Анна", Content: "Ответ на удаляемый"}
+	require.NoError(t, storage.CreateComment(ctx, c2))
+	_, err := storage.EditComment(ctx, c1.ID, "Удаляемый, исправленный")
+	require.NoError(t, err)
+
+	deleted, err := storage.DeleteComment(ctx, c1.ID)
+	require.NoError(t, err)
+	assert.True(t, deleted.Deleted)
+	assert.Empty(t, deleted.Content)
+	assert.Equal(t, c1.Path, deleted.Path)
+
+	// ветка ответов сохраняется вместе с удаленным комментарием
+	replies, err := storage.GetReplies(ctx, root.ID, pagination.RepliesPage{})
+	require.NoError(t, err)
+	require.Len(t, replies, 2)
+	assert.Equal(t, c1.ID, replies[0].ID)
+	assert.True(t, replies[0].Deleted)
+	assert.Equal(t, c2.ID, replies[1].ID)
+
+	revisions, err := storage.GetCommentRevisions(ctx, c1.ID)
+	require.NoError(t, err)
+	assert.Empty(t, revisions, "история изменений удаленного комментария стирается")
+
+	_, err = storage.EditComment(ctx, c1.ID, "Воскрешение")
+	assert.Error(t, err, "удаленный комментарий нельзя редактировать")
+}
+
+func TestGetCommentByID(t *testing.T) {
+	post := &model.Post{Title: "Пост", AreCommentsAllowed: true}
+	require.NoError(t, storage.CreatePost(ctx, post), "пост не создан")
+	root := &model.Comment{PostID: post.ID, Author: "Анна", Content: "Корневой"}
+	require.NoError(t, storage.CreateComment(ctx, root))
+	reply := &model.Comment{PostID: post.ID, ParentCommentID: &root.ID, Author: "Олег", Content: "Ответ"}
+	require.NoError(t, storage.CreateComment(ctx, reply))
+
+	comment, err := storage.GetCommentByID(ctx, reply.ID)
+	require.NoError(t, err)
+	assert.Equal(t, "Ответ", comment.Content)
+	assert.Equal(t, post.ID, comment.PostID)
+	require.NotNil(t, comment.ParentCommentID)
+	assert.Equal(t, root.ID, *comment.ParentCommentID)
+	assert.Equal(t, reply.Path, comment.Path)
+
+	_, err = storage.GetCommentByID(ctx, -1)
+	assert.Error(t, err)
+}
+
+func TestGetPostsAndCommentsByIDs(t *testing.T) {
+	first := &model.Post{Title: "Первый", AreCommentsAllowed: true}
+	second := &model.Post{Title: "Второй"}
+	require.NoError(t, storage.CreatePost(ctx, first), "пост не создан")
+	require.NoError(t, storage.CreatePost(ctx, second), "пост не создан")
+	root := &model.Comment{PostID: first.ID, Author: "Анна", Content: "Корневой"}
+	require.NoError(t, storage.CreateComment(ctx, root))
+	reply := &model.Comment{PostID: first.ID, ParentCommentID: &root.ID, Author: "Олег", Content: "Ответ"}
+	require.NoError(t, storage.CreateComment(ctx, reply))
+
+	// несуществующих id в ответе просто нет
+	posts, err := storage.GetPostsByIDs(ctx, []int{first.ID, second.ID, -1})
+	require.NoError(t, err)
+	require.Len(t, posts, 2)
+	assert.Equal(t, "Первый", posts[first.ID].Title)
+	assert.Equal(t, "Второй", posts[second.ID].Title)
+
+	comments, err := storage.GetCommentsByIDs(ctx, []int{root.ID, reply.ID, -1})
+	require.NoError(t, err)
+	require.Len(t, comments, 2)
+	assert.Equal(t, "Корневой", comments[root.ID].Content)
+	require.NotNil(t, comments[reply.ID].ParentCommentID)
+	assert.Equal(t, root.ID, *comments[reply.ID].ParentCommentID)
+	assert.Equal(t, reply.Path, comments[reply.ID].Path)
+}
+
+func TestGetPostsPage(t *testing.T) {
+	// база общая для всех тестов пакета, поэтому страницы ограничиваются постами-границами
+	// до и после созданных в тесте, а не очисткой таблицы
+	createPost := func(title string) *model.Post {
+		p := &model.Post{Title: title, Content: "Текст", Author: "Даша"}
+		require.NoError(t, storage.CreatePost(ctx, p), "пост не создан")
+		return p
+	}
+	older := createPost("Граница снизу")
+	var ids []int
+	for i := 1; i <= 5; i++ {
+		ids = append(ids, createPost(fmt.Sprintf("Пост %d", i)).ID)
+	}
+	newer := createPost("Граница сверху")
+	top := pagination.NewCursor(newer.CreatedAt, newer.ID)
+	bottom := pagination.NewCursor(older.CreatedAt, older.ID)
+
+	posts, hasMore, err := storage.GetPostsPage(ctx, pagination.Page{After: &top, Limit: 2})
+	require.NoError(t, err)
+	assert.True(t, hasMore)
+	require.Len(t, posts, 2)
+	assert.Equal(t, ids[4], posts[0].ID)
+	assert.Equal(t, ids[3], posts[1].ID)
+
+	after := pagination.NewCursor(posts[1].CreatedAt, posts[1].ID)
+	posts, hasMore, err = storage.GetPostsPage(ctx, pagination.Page{After: &after, Before: &bottom, Limit: 3})
+	require.NoError(t, err)
+	assert.False(t, hasMore)
+	require.Len(t, posts, 3)
+	assert.Equal(t, ids[2], posts[0].ID)
+	assert.Equal(t, ids[0], posts[2].ID)
+
+	before := pagination.NewCursor(posts[2].CreatedAt, posts[2].ID)
+	posts, hasMore, err = storage.GetPostsPage(ctx, pagination.Page{Before: &before, Limit: 2, Backward: true})
+	require.NoError(t, err)
+	assert.True(t, hasMore)
+	require.Len(t, posts, 2)
+	assert.Equal(t, ids[2], posts[0].ID)
+	assert.Equal(t, ids[1], posts[1].ID)
+}
+
+func TestGetCommentsPage(t *testing.T) {
+	post := &model.Post{Title: "Пост для пагинации", Content: "Контент", Author: "Даша", AreCommentsAllowed: true}
+	require.NoError(t, storage.CreatePost(ctx, post), "пост не создан")
+
+	var ids []int
+	for i := 1; i <= 5; i++ {
+		c := &model.Comment{PostID: post.ID, Author: "Анна", Content: fmt.Sprintf("Коммент %d", i)}
+		require.NoError(t, storage.CreateComment(ctx, c))
+		ids = append(ids, c.ID)
+		reply := &model.Comment{PostID: post.ID, ParentCommentID: &c.ID, Author: "Олег", Content: "Ответ"}
+		require.NoError(t, storage.CreateComment(ctx, reply))
+	}
+
+	comments, hasMore, err := storage.GetCommentsPage(ctx, post.ID, pagination.Page{Limit: 2})
+	require.NoError(t, err)
+	assert.True(t, hasMore)
+	require.Len(t, comments, 2)
+	assert.Equal(t, ids[0], comments[0].ID)
+	assert.Equal(t, ids[1], comments[1].ID)
+
+	// новый корневой комментарий не сдвигает следующую страницу
+	fresh := &model.Comment{PostID: post.ID, Author: "Анна", Content: "Свежий"}
+	require.NoError(t, storage.CreateComment(ctx, fresh))
+
+	after := pagination.NewCursor(comments[1].CreatedAt, comments[1].ID)
+	comments, hasMore, err = storage.GetCommentsPage(ctx, post.ID, pagination.Page{After: &after, Limit: 2})
+	require.NoError(t, err)
+	assert.True(t, hasMore)
+	require.Len(t, comments, 2)
+	assert.Equal(t, ids[2], comments[0].ID)
+	assert.Equal(t, ids[3], comments[1].ID)
+
+	before := pagination.NewCursor(comments[0].CreatedAt, comments[0].ID)
+	comments, hasMore, err = storage.GetCommentsPage(ctx, post.ID, pagination.Page{Before: &before, Limit: 1, Backward: true})
+	require.NoError(t, err)
+	assert.True(t, hasMore)
+	require.Len(t, comments, 1)
+	assert.Equal(t, ids[1], comments[0].ID)
+}
+
+func TestGetReplies_MaxDepthAndPaging(t *testing.T) {
+	post := &model.Post{Title: "Вирусный тред", Content: "Текст", Author: "Даша", AreCommentsAllowed: true}
+	require.NoError(t, storage.CreatePost(ctx, post), "пост не создан")
+
+	root := &model.Comment{PostID: post.ID}
+	require.NoError(t, storage.CreateComment(ctx, root))
+
+	// три прямых ответа, у каждого ветка глубиной 2
+	var siblings, children, grandchildren []int
+	for i := 0; i < 3; i++ {
+		s := &model.Comment{PostID: post.ID, ParentCommentID: &root.ID}
+		require.NoError(t, storage.CreateComment(ctx, s))
+		c := &model.Comment{PostID: post.ID, ParentCommentID: &s.ID}
+		require.NoError(t, storage.CreateComment(ctx, c))
+		g := &model.Comment{PostID: post.ID, ParentCommentID: &c.ID}
+		require.NoError(t, storage.CreateComment(ctx, g))
+		siblings = append(siblings, s.ID)
+		children = append(children, c.ID)
+		grandchildren = append(grandchildren, g.ID)
+	}
+
+	replies, err := storage.GetReplies(ctx, root.ID, pagination.RepliesPage{MaxDepth: 2, First: 2})
+	require.NoError(t, err)
+	expected := []int{siblings[0], children[0], siblings[1], children[1]}
+	require.Len(t, replies, len(expected))
+	for i, r := range replies {
+		assert.Equal(t, expected[i], r.ID)
+	}
+
+	replies, err = storage.GetReplies(ctx, root.ID, pagination.RepliesPage{MaxDepth: 2, First: 2, AfterID: siblings[1]})
+	require.NoError(t, err)
+	expected = []int{siblings[2], children[2]}
+	require.Len(t, replies, len(expected))
+	for i, r := range replies {
+		assert.Equal(t, expected[i], r.ID)
+	}
+
+	replies, err = storage.GetReplies(ctx, children[0], pagination.RepliesPage{MaxDepth: 1})
+	require.NoError(t, err)
+	require.Len(t, replies, 1)
+	assert.Equal(t, grandchildren[0], replies[0].ID)
+
+	// у комментария без ответов - пустая страница, у несуществующего - ошибка
+	replies, err = storage.GetReplies(ctx, grandchildren[0], pagination.RepliesPage{})
+	require.NoError(t, err)
+	assert.Empty(t, replies)
+	_, err = storage.GetReplies(ctx, -1, pagination.RepliesPage{})
+	require.ErrorIs(t, err, store.ErrCommentNotFound)
+}
+
+// setNextCommentID Следующий комментарий получит указанный id
+func setNextCommentID(t *testing.T, id int) {
+	_, err := db.Exec("SELECT setval('comments_id_seq', $1, false)", id)
+	require.NoError(t, err)
+}
+
+// idsAcrossDigits Два id больше текущего, у которых порядок строк обратный порядку чисел: 9..0 и 10..0
+func idsAcrossDigits(current int) (int, int) {
+	pow := 1
+	for pow <= current {
+		pow *= 10
+	}
+	return 9 * pow, 10 * pow
+}
+
+func TestGetReplies_OrderByNumericID(t *testing.T) {
+	post := &model.Post{Title: "Пост", Content: "Текст", Author: "Даша", AreCommentsAllowed: true}
+	require.NoError(t, storage.CreatePost(ctx, post), "пост не создан")
+
+	root := &model.Comment{PostID: post.ID}
+	require.NoError(t, storage.CreateComment(ctx, root))
+
+	// как текст метка "10..0" меньше "9..0", но ответ с меньшим id должен идти первым
+	first, second := idsAcrossDigits(root.ID)
+	setNextCommentID(t, first)
+	a := &model.Comment{PostID: post.ID, ParentCommentID: &root.ID}
+	require.NoError(t, storage.CreateComment(ctx, a))
+	aChild := &model.Comment{PostID: post.ID, ParentCommentID: &a.ID}
+	require.NoError(t, storage.CreateComment(ctx, aChild))
+	setNextCommentID(t, second)
+	b := &model.Comment{PostID: post.ID, ParentCommentID: &root.ID}
+	require.NoError(t, storage.CreateComment(ctx, b))
+	bChild := &model.Comment{PostID: post.ID, ParentCommentID: &b.ID}
+	require.NoError(t, storage.CreateComment(ctx, bChild))
+
+	replies, err := storage.GetReplies(ctx, root.ID, pagination.RepliesPage{})
+	require.NoError(t, err)
+	expected := []int{a.ID, aChild.ID, b.ID, bChild.ID}
+	require.Len(t, replies, len(expected))
+	for i, r := range replies {
+		assert.Equal(t, expected[i], r.ID)
+	}
+
+	// курсор - id последнего прямого ответа страницы, следующая страница начинается сразу после него
+	replies, err = storage.GetReplies(ctx, root.ID, pagination.RepliesPage{First: 1, AfterID: a.ID})
+	require.NoError(t, err)
+	require.Len(t, replies, 2)
+	assert.Equal(t, b.ID, replies[0].ID)
+	assert.Equal(t, bChild.ID, replies[1].ID)
+}
+
+func TestGetCommentSubtree(t *testing.T) {
+	post := &model.Post{Title: "Пост", Content: "Текст", Author: "Даша", AreCommentsAllowed: true}
+	require.NoError(t, storage.CreatePost(ctx, post), "пост не создан")
+
+	root := &model.Comment{PostID: post.ID}
+	require.NoError(t, storage.CreateComment(ctx, root))
+	c1 := &model.Comment{PostID: post.ID, ParentCommentID: &root.ID}
+	require.NoError(t, storage.CreateComment(ctx, c1))
+	c2 := &model.Comment{PostID: post.ID, ParentCommentID: &c1.ID}
+	require.NoError(t, storage.CreateComment(ctx, c2))
+	c3 := &model.Comment{PostID: post.ID, ParentCommentID: &c2.ID}
+	require.NoError(t, storage.CreateComment(ctx, c3))
+	c4 := &model.Comment{PostID: post.ID, ParentCommentID: &root.ID}
+	require.NoError(t, storage.CreateComment(ctx, c4))
+
+	subtree, err := storage.GetCommentSubtree(ctx, root.ID, 2)
+	require.NoError(t, err)
+	expected := []int{root.ID, c1.ID, c2.ID, c4.ID}
+	require.Len(t, subtree, len(expected))
+	for i, c := range subtree {
+		assert.Equal(t, expected[i], c.ID)
+	}
+	assert.Equal(t, 2, subtree[0].ChildCount)
+	assert.Equal(t, 1, subtree[2].ChildCount, "у свернутого узла есть непоказанный ответ")
+	assert.Equal(t, 0, subtree[3].ChildCount)
+}
+
+func TestGetCommentSubtree_OrderByNumericID(t *testing.T) {
+	post := &model.Post{Title: "Пост", Content: "Текст", Author: "Даша", AreCommentsAllowed: true}
+	require.NoError(t, storage.CreatePost(ctx, post), "пост не создан")
+
+	root := &model.Comment{PostID: post.ID}
+	require.NoError(t, storage.CreateComment(ctx, root))
+
+	first, second := idsAcrossDigits(root.ID)
+	setNextCommentID(t, first)
+	a := &model.Comment{PostID: post.ID, ParentCommentID: &root.ID}
+	require.NoError(t, storage.CreateComment(ctx, a))
+	setNextCommentID(t, second)
+	b := &model.Comment{PostID: post.ID, ParentCommentID: &root.ID}
+	require.NoError(t, storage.CreateComment(ctx, b))
+	aChild := &model.Comment{PostID: post.ID, ParentCommentID: &a.ID}
+	require.NoError(t, storage.CreateComment(ctx, aChild))
+
+	subtree, err := storage.GetCommentSubtree(ctx, root.ID, 0)
+	require.NoError(t, err)
+	expected := []int{root.ID, a.ID, aChild.ID, b.ID}
+	require.Len(t, subtree, len(expected))
+	for i, c := range subtree {
+		assert.Equal(t, expected[i], c.ID)
+	}
+}
+
+func TestGetCommentAncestors(t *testing.T) {
+	post := &model.Post{Title: "Пост", AreCommentsAllowed: true}
+	require.NoError(t, storage.CreatePost(ctx, post), "пост не создан")
+
+	root := &model.Comment{PostID: post.ID, Content: "Корневой"}
+	require.NoError(t, storage.CreateComment(ctx, root))
+	c1 := &model.Comment{PostID: post.ID, ParentCommentID: &root.ID, Content: "Уровень 1"}
+	require.NoError(t, storage.CreateComment(ctx, c1))
+	sibling := &model.Comment{PostID: post.ID, ParentCommentID: &root.ID, Content: "Соседняя ветка"}
+	require.NoError(t, storage.CreateComment(ctx, sibling))
+	c2 := &model.Comment{PostID: post.ID, ParentCommentID: &c1.ID, Content: "Уровень 2"}
+	require.NoError(t, storage.CreateComment(ctx, c2))
+	c3 := &model.Comment{PostID: post.ID, ParentCommentID: &c2.ID, Content: "Уровень 3"}
+	require.NoError(t, storage.CreateComment(ctx, c3))
+
+	ancestors, err := storage.GetCommentAncestors(ctx, c3.ID)
+	require.NoError(t, err)
+	require.Len(t, ancestors, 3)
+	assert.Equal(t, root.ID, ancestors[0].ID)
+	assert.Equal(t, c1.ID, ancestors[1].ID)
+	assert.Equal(t, c2.ID, ancestors[2].ID)
+
+	ancestors, err = storage.GetCommentAncestors(ctx, root.ID)
+	require.NoError(t, err)
+	assert.Empty(t, ancestors)
+
+	_, err = storage.GetCommentAncestors(ctx, -1)
+	require.ErrorIs(t, err, store.ErrCommentNotFound)
+}
+
+func TestGetCommentCounts(t *testing.T) {
+	post := &model.Post{Title: "Пост", Content: "Текст", Author: "Даша", AreCommentsAllowed: true}
+	require.NoError(t, storage.CreatePost(ctx, post), "пост не создан")
+
+	root := &model.Comment{PostID: post.ID}
+	require.NoError(t, storage.CreateComment(ctx, root))
+	c1 := &model.Comment{PostID: post.ID, ParentCommentID: &root.ID}
+	require.NoError(t, storage.CreateComment(ctx, c1))
+	c2 := &model.Comment{PostID: post.ID, ParentCommentID: &c1.ID}
+	require.NoError(t, storage.CreateComment(ctx, c2))
+	c3 := &model.Comment{PostID: post.ID, ParentCommentID: &c1.ID}
+	require.NoError(t, storage.CreateComment(ctx, c3))
+	c4 := &model.Comment{PostID: post.ID, ParentCommentID: &root.ID}
+	require.NoError(t, storage.CreateComment(ctx, c4))
+
+	counts, err := storage.GetCommentCounts(ctx, []int{root.ID, c1.ID, c2.ID, -1})
+	require.NoError(t, err)
+	assert.Len(t, counts, 3)
+	assert.Equal(t, model.CommentCounts{CommentID: root.ID, ReplyCount: 2, DescendantCount: 4}, counts[root.ID])
+	assert.Equal(t, model.CommentCounts{CommentID: c1.ID, ReplyCount: 2, DescendantCount: 2}, counts[c1.ID])
+	assert.Equal(t, model.CommentCounts{CommentID: c2.ID}, counts[c2.ID])
+}
+
+func TestCommentCountAndLastActivity(t *testing.T) {
+	older := &model.Post{Title: "Старый", Content: "Текст", Author: "Даша", AreCommentsAllowed: true}
+	require.NoError(t, storage.CreatePost(ctx, older), "пост не создан")
+	time.Sleep(time.Millisecond)
+	newer := &model.Post{Title: "Новый", Content: "Текст", Author: "Аня", AreCommentsAllowed: true}
+	require.NoError(t, storage.CreatePost(ctx, newer), "пост не создан")
+	time.Sleep(time.Millisecond)
+
+	root := &model.Comment{PostID: older.ID, Author: "Даша", Content: "Коммент"}
+	require.NoError(t, storage.CreateComment(ctx, root))
+	reply := &model.Comment{PostID: older.ID, Author: "Аня", Content: "Ответ", ParentCommentID: &root.ID}
+	require.NoError(t, storage.CreateComment(ctx, reply))
+
+	post, err := storage.GetPostByID(ctx, older.ID)
+	require.NoError(t, err)
+	assert.Equal(t, 2, post.CommentCount)
+	require.NotNil(t, post.LastCommentAt)
+	assert.Equal(t, reply.CreatedAt.UnixMicro(), post.LastCommentAt.UnixMicro())
+
+	post, err = storage.GetPostByID(ctx, newer.ID)
+	require.NoError(t, err)
+	assert.Zero(t, post.CommentCount)
+	assert.Nil(t, post.LastCommentAt)
+
+	posts, err := storage.GetAllPosts(ctx, model.PostsFilter{Order: model.PostOrderLastActivity})
+	require.NoError(t, err)
+	require.GreaterOrEqual(t, len(posts), 2)
+	assert.Equal(t, older.ID, posts[0].ID, "пост с последним комментарием должен быть первым")
+
+	posts, err = storage.GetAllPosts(ctx, model.PostsFilter{Order: model.PostOrderCreatedAt})
+	require.NoError(t, err)
+	assert.Equal(t, newer.ID, posts[0].ID)
+}
+
+func TestGetCommentsByPosts(t *testing.T) {
+	first := &model.Post{Title: "Первый", Content: "Текст", Author: "Даша", AreCommentsAllowed: true}
+	require.NoError(t, storage.CreatePost(ctx, first), "пост не создан")
+	second := &model.Post{Title: "Второй", Content: "Текст", Author: "Аня", AreCommentsAllowed: true}
+	require.NoError(t, storage.CreatePost(ctx, second), "пост не создан")
+	empty := &model.Post{Title: "Без комментариев", Content: "Текст", Author: "Аня", AreCommentsAllowed: true}
+	require.NoError(t, storage.CreatePost(ctx, empty), "пост не создан")
+
+	var firstIDs []int
+	for i := 0; i < 3; i++ {
+		c := &model.Comment{PostID: first.ID, Author: "Даша", Content: "Коммент"}
+		require.NoError(t, storage.CreateComment(ctx, c))
+		firstIDs = append(firstIDs, c.ID)
+	}
+	secondRoot := &model.Comment{PostID: second.ID, Author: "Аня", Content: "Коммент"}
+	require.NoError(t, storage.CreateComment(ctx, secondRoot))
+	reply := &model.Comment{PostID: second.ID, Author: "Аня", Content: "Ответ", ParentCommentID: &secondRoot.ID}
+	require.NoError(t, storage.CreateComment(ctx, reply))
+
+	comments, amounts, err := storage.GetCommentsByPosts(ctx, []int{first.ID, second.ID, empty.ID}, 2, 1)
+	require.NoError(t, err)
+
+	require.Len(t, comments[first.ID], 2)
+	assert.Equal(t, firstIDs[1], comments[first.ID][0].ID)
+	assert.Equal(t, firstIDs[2], comments[first.ID][1].ID)
+	assert.Equal(t, 3, amounts[first.ID])
+
+	assert.Empty(t, comments[second.ID], "ответы в корневые комментарии не попадают, а страница начинается после единственного корневого")
+	assert.Equal(t, 1, amounts[second.ID])
+
+	assert.NotNil(t, comments[empty.ID])
+	assert.Empty(t, comments[empty.ID])
+	assert.Zero(t, amounts[empty.ID])
+}
+
+func TestGetRevisionsByComments(t *testing.T) {
+	post := &model.Post{Title: "Пост", Content: "Текст", Author: "Даша", AreCommentsAllowed: true}
+	require.NoError(t, storage.CreatePost(ctx, post), "пост не создан")
+
+	edited := &model.Comment{PostID: post.ID, Author: "Даша", Content: "v1"}
+	require.NoError(t, storage.CreateComment(ctx, edited))
+	untouched := &model.Comment{PostID: post.ID, Author: "Даша", Content: "v1"}
+	require.NoError(t, storage.CreateComment(ctx, untouched))
+
+	_, err := storage.EditComment(ctx, edited.ID, "v2")
+	require.NoError(t, err)
+	_, err = storage.EditComment(ctx, edited.ID, "v3")
+	require.NoError(t, err)
+
+	revisions, err := storage.GetRevisionsByComments(ctx, []int{edited.ID, untouched.ID})
+	require.NoError(t, err)
+	require.Len(t, revisions[edited.ID], 2)
+	assert.Equal(t, "v1", revisions[edited.ID][0].Content)
+	assert.Equal(t, "v2", revisions[edited.ID][1].Content)
+	assert.NotNil(t, revisions[untouched.ID])
+	assert.Empty(t, revisions[untouched.ID])
+}
+
+func TestGetRepliesByParents(t *testing.T) {
+	post := &model.Post{Title: "Пост", Content: "Текст", Author: "Даша", AreCommentsAllowed: true}
+	require.NoError(t, storage.CreatePost(ctx, post), "пост не создан")
+
+	root := &model.Comment{PostID: post.ID, Author: "Даша", Content: "Корень"}
+	require.NoError(t, storage.CreateComment(ctx, root))
+	first := &model.Comment{PostID: post.ID, ParentCommentID: &root.ID, Author: "Даша", Content: "Первый"}
+	require.NoError(t, storage.CreateComment(ctx, first))
+	second := &model.Comment{PostID: post.ID, ParentCommentID: &root.ID, Author: "Даша", Content: "Второй"}
+	require.NoError(t, storage.CreateComment(ctx, second))
+	nested := &model.Comment{PostID: post.ID, ParentCommentID: &first.ID, Author: "Даша", Content: "Вложенный"}
+	require.NoError(t, storage.CreateComment(ctx, nested))
+
+	replies, err := storage.GetRepliesByParents(ctx, []int{root.ID, second.ID})
+	require.NoError(t, err)
+	require.Len(t, replies[root.ID], 2, "только прямые ответы")
+	assert.Equal(t, first.ID, replies[root.ID][0].ID)
+	assert.Equal(t, second.ID, replies[root.ID][1].ID)
+	assert.NotNil(t, replies[second.ID])
+	assert.Empty(t, replies[second.ID])
+}
+
+func TestGetAllPosts_CreatedAfter(t *testing.T) {
+	older := &model.Post{Title: "Старый", Content: "Текст", Author: "Даша"}
+	require.NoError(t, storage.CreatePost(ctx, older), "пост не создан")
+	time.Sleep(time.Millisecond)
+	newer := &model.Post{Title: "Новый", Content: "Текст", Author: "Аня"}
+	require.NoError(t, storage.CreatePost(ctx, newer), "пост не создан")
+
+	posts, err := storage.GetAllPosts(ctx, model.PostsFilter{Order: model.PostOrderCreatedAt, CreatedAfter: &older.CreatedAt})
+	require.NoError(t, err)
+	require.Len(t, posts, 1, "граница не включается")
+	assert.Equal(t, newer.ID, posts[0].ID)
+}
+
+func TestCreateComment_Outbox(t *testing.T) {
+	post := &model.Post{Title: "Пост", Content: "Текст", Author: "Даша", AreCommentsAllowed: true}
+	require.NoError(t, storage.CreatePost(ctx, post), "пост не создан")
+	comment := &model.Comment{PostID: post.ID, Author: "Анна", Content: "В outbox"}
+	require.NoError(t, storage.CreateComment(ctx, comment))
+	// правка до отправки не меняет уведомление: в outbox снимок на момент создания
+	_, err := storage.EditComment(ctx, comment.ID, "Исправлено")
+	require.NoError(t, err)
+
+	var message *model.OutboxMessage
+	var claimedByOther []model.OutboxMessage
+	_, err = storage.DeliverNotifications(ctx, 1000, func(m model.OutboxMessage) error {
+		if claimedByOther == nil {
+			// пока пачка не отправлена, другой relay не получает ее уведомления
+			claimedByOther = []model.OutboxMessage{}
+			_, err := storage.DeliverNotifications(ctx, 1000, func(other model.OutboxMessage) error {
+				claimedByOther = append(claimedByOther, other)
+				return nil
+			})
+			require.NoError(t, err)
+		}
+		if m.Comment.ID == comment.ID {
+			message = &m
+		}
+		return nil
+	})
+	require.NoError(t, err)
+	require.NotNil(t, message, "уведомление записано в той же транзакции, что и комментарий")
+	assert.Equal(t, "В outbox", message.Comment.Content)
+	assert.Nil(t, message.Comment.EditedAt)
+	assert.Equal(t, post.ID, message.Comment.PostID)
+	assert.Equal(t, comment.Path, message.Comment.Path)
+	for _, other := range claimedByOther {
+		assert.NotEqual(t, message.ID, other.ID)
+	}
+
+	_, err = storage.DeliverNotifications(ctx, 1000, func(m model.OutboxMessage) error {
+		assert.NotEqual(t, message.ID, m.ID, "отправленное уведомление не должно отдаваться повторно")
+		return nil
+	})
+	require.NoError(t, err)
+
+	snapshot, err := storage.GetCommentSnapshot(ctx, comment.ID)
+	require.NoError(t, err)
+	assert.Equal(t, "В outbox", snapshot.Content)
+
+	purged, err := storage.PurgeDeliveredNotifications(ctx, time.Now().UTC().Add(time.Minute))
+	require.NoError(t, err)
+	assert.Positive(t, purged)
+	_, err = storage.GetCommentSnapshot(ctx, comment.ID)
+	require.ErrorIs(t, err, store.ErrCommentNotFound)
+}
+
+func TestDeliverNotifications_PublishError(t *testing.T) {
+	post := &model.Post{Title: "Пост", Content: "Текст", Author: "Даша", AreCommentsAllowed: true}
+	require.NoError(t, storage.CreatePost(ctx, post), "пост не создан")
+	comment := &model.Comment{PostID: post.ID, Author: "Анна", Content: "Не ушел"}
+	require.NoError(t, storage.CreateComment(ctx, comment))
+
+	publishErr := fmt.Errorf("соединение потеряно")
+	_, err := storage.DeliverNotifications(ctx, 1000, func(m model.OutboxMessage) error {
+		if m.Comment.ID == comment.ID {
+			return publishErr
+		}
+		return nil
+	})
+	require.ErrorIs(t, err, publishErr)
+
+	// неотправленное уведомление остается в outbox
+	found := false
+	_, err = storage.DeliverNotifications(ctx, 1000, func(m model.OutboxMessage) error {
+		found = found || m.Comment.ID == comment.ID
+		return nil
+	})
+	require.NoError(t, err)
+	assert.True(t, found)
+}
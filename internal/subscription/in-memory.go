@@ -2,50 +2,70 @@ package subscription
 
 import (
 	"OzonTestTask/internal/model"
+	"context"
 	"sync"
 )
 
 type InMemorySubscription struct {
 	mu          sync.RWMutex
-	subscribers map[int][]SubscriptionChan
+	subscribers map[int]map[*subscriber]struct{}
+	opts        Options
+	active      sync.WaitGroup // горутины, удаляющие подписчиков после отмены контекста
 }
 
-func NewInMemorySubscription() *InMemorySubscription {
-	return &InMemorySubscription{subscribers: make(map[int][]SubscriptionChan)}
+func NewInMemorySubscription(opts Options) *InMemorySubscription {
+	return &InMemorySubscription{subscribers: make(map[int]map[*subscriber]struct{}), opts: opts.normalized()}
 }
 
-// Subscribe Добавление подписчиков на пост
-func (sub *InMemorySubscription) Subscribe(postID int) SubscriptionChan {
-	ch := make(SubscriptionChan)
+// Subscribe Добавление подписчика на пост. После отмены ctx подписчик удаляется, а канал закрывается
+func (sub *InMemorySubscription) Subscribe(ctx context.Context, postID int) SubscriptionChan {
+	s := newSubscriber(ctx, sub.opts)
 	sub.mu.Lock()
-	defer sub.mu.Unlock()
-	sub.subscribers[postID] = append(sub.subscribers[postID], ch)
-	return ch
+	if sub.subscribers[postID] == nil {
+		sub.subscribers[postID] = make(map[*subscriber]struct{})
+	}
+	sub.subscribers[postID][s] = struct{}{}
+	sub.mu.Unlock()
+
+	sub.active.Add(1)
+	go func() {
+		defer sub.active.Done()
+		<-s.ctx.Done()
+		sub.mu.Lock()
+		delete(sub.subscribers[postID], s)
+		if len(sub.subscribers[postID]) == 0 {
+			delete(sub.subscribers, postID)
+		}
+		sub.mu.Unlock()
+		s.close()
+	}()
+	return s.ch
 }
 
 // Publish Публикация нового комментария в канал
 func (sub *InMemorySubscription) Publish(postID int, comment *model.Comment) error {
 	sub.mu.RLock()
-	postSubs := sub.subscribers[postID]
+	postSubs := make([]*subscriber, 0, len(sub.subscribers[postID]))
+	for s := range sub.subscribers[postID] {
+		postSubs = append(postSubs, s)
+	}
 	sub.mu.RUnlock()
 
-	for _, postSub := range postSubs {
-		go func(s SubscriptionChan) {
-			s <- comment
-		}(postSub)
+	for _, s := range postSubs {
+		s.send(comment)
 	}
 	return nil
 }
 
-// Close Закрытие всех каналов и очистка структуры
+// Close Отписка всех подписчиков и закрытие их каналов
 func (sub *InMemorySubscription) Close() error {
-	sub.mu.Lock()
-	defer sub.mu.Unlock()
-	for _, v := range sub.subscribers {
-		for _, ch := range v {
-			close(ch)
+	sub.mu.RLock()
+	for _, postSubs := range sub.subscribers {
+		for s := range postSubs {
+			s.cancel()
 		}
 	}
-	sub.subscribers = make(map[int][]SubscriptionChan)
+	sub.mu.RUnlock()
+	sub.active.Wait()
 	return nil
 }
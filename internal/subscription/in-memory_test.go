@@ -2,13 +2,48 @@ package subscription
 
 import (
 	"OzonTestTask/internal/model"
+	"context"
+	"expvar"
 	"github.com/stretchr/testify/require"
+	"runtime"
 	"testing"
+	"time"
 )
 
+var ctx = context.Background()
+
+// requireNoLeak Проверка, что после отписки не осталось лишних горутин
+func requireNoLeak(t *testing.T, before int) {
+	t.Helper()
+	// без require.Eventually: он сам проверяет условие в отдельной горутине
+	deadline := time.Now().Add(time.Second)
+	for runtime.NumGoroutine() > before {
+		if time.Now().After(deadline) {
+			t.Fatalf("горутин до теста %d, после - %d", before, runtime.NumGoroutine())
+		}
+		time.Sleep(5 * time.Millisecond)
+	}
+}
+
+// requireClosed Проверка, что канал подписчика закрывается. Комментарии, отправленные до отписки, пропускаются
+func requireClosed(t *testing.T, ch SubscriptionChan) {
+	t.Helper()
+	timeout := time.After(time.Second)
+	for {
+		select {
+		case _, ok := <-ch:
+			if !ok {
+				return
+			}
+		case <-timeout:
+			t.Fatal("канал не закрыт после отписки")
+		}
+	}
+}
+
 func TestSubscribeAndPublish(t *testing.T) {
-	sub := NewInMemorySubscription()
-	ch := sub.Subscribe(1)
+	sub := NewInMemorySubscription(DefaultOptions())
+	ch := sub.Subscribe(ctx, 1)
 	require.NotNil(t, ch)
 	comment := &model.Comment{ID: 1, Author: "Дарья", Content: "Коммент"}
 	go func() {
@@ -20,9 +55,9 @@ func TestSubscribeAndPublish(t *testing.T) {
 }
 
 func TestManySubscribers(t *testing.T) {
-	sub := NewInMemorySubscription()
-	ch1 := sub.Subscribe(1)
-	ch2 := sub.Subscribe(1)
+	sub := NewInMemorySubscription(DefaultOptions())
+	ch1 := sub.Subscribe(ctx, 1)
+	ch2 := sub.Subscribe(ctx, 1)
 	comment := &model.Comment{ID: 1, Author: "Саша", Content: "Новый коммент"}
 
 	go func() {
@@ -37,9 +72,9 @@ func TestManySubscribers(t *testing.T) {
 }
 
 func TestSubscribeDifferentPosts(t *testing.T) {
-	sub := NewInMemorySubscription()
-	ch1 := sub.Subscribe(1)
-	ch2 := sub.Subscribe(2)
+	sub := NewInMemorySubscription(DefaultOptions())
+	ch1 := sub.Subscribe(ctx, 1)
+	ch2 := sub.Subscribe(ctx, 2)
 	comment1 := &model.Comment{ID: 1, Content: "Пост 1"}
 	comment2 := &model.Comment{ID: 2, Content: "Пост 2"}
 
@@ -58,8 +93,8 @@ func TestSubscribeDifferentPosts(t *testing.T) {
 }
 
 func TestClose(t *testing.T) {
-	sub := NewInMemorySubscription()
-	ch1 := sub.Subscribe(1)
+	sub := NewInMemorySubscription(DefaultOptions())
+	ch1 := sub.Subscribe(ctx, 1)
 	err := sub.Close()
 	require.NoError(t, err)
 	select {
@@ -69,3 +104,158 @@ func TestClose(t *testing.T) {
 		t.Fatal("ch1 должен быть закрыт")
 	}
 }
+
+func TestUnsubscribeOnContextCancel(t *testing.T) {
+	before := runtime.NumGoroutine()
+	sub := NewInMemorySubscription(DefaultOptions())
+	subCtx, cancel := context.WithCancel(ctx)
+	ch := sub.Subscribe(subCtx, 1)
+	other := sub.Subscribe(ctx, 1)
+
+	// комментарий, который подписчик так и не прочитал, не держит горутину после отписки
+	require.NoError(t, sub.Publish(1, &model.Comment{ID: 1}))
+	cancel()
+	requireClosed(t, ch)
+
+	require.Eventually(t, func() bool {
+		sub.mu.RLock()
+		defer sub.mu.RUnlock()
+		return len(sub.subscribers[1]) == 1
+	}, time.Second, 5*time.Millisecond, "отписавшийся подписчик должен быть удален")
+	require.Equal(t, 1, (<-other).ID)
+
+	// публикация после отписки не отправляет в закрытый канал
+	require.NoError(t, sub.Publish(1, &model.Comment{ID: 2}))
+	require.Equal(t, 2, (<-other).ID)
+
+	require.NoError(t, sub.Close())
+	requireClosed(t, other)
+	require.Empty(t, sub.subscribers)
+	requireNoLeak(t, before)
+}
+
+func TestUnsubscribe_ManyClients(t *testing.T) {
+	before := runtime.NumGoroutine()
+	sub := NewInMemorySubscription(DefaultOptions())
+	subCtx, cancel := context.WithCancel(ctx)
+	channels := make([]SubscriptionChan, 100)
+	for i := range channels {
+		channels[i] = sub.Subscribe(subCtx, i%3)
+	}
+	for postID := range 3 {
+		require.NoError(t, sub.Publish(postID, &model.Comment{ID: postID}))
+	}
+
+	// разрыв всех соединений
+	cancel()
+	for _, ch := range channels {
+		for range ch {
+		}
+	}
+	require.Eventually(t, func() bool {
+		sub.mu.RLock()
+		defer sub.mu.RUnlock()
+		return len(sub.subscribers) == 0
+	}, time.Second, 5*time.Millisecond)
+	requireNoLeak(t, before)
+}
+
+// publishUnread Публикация комментариев с id от 1 до n подписчику, который их пока не читает
+func publishUnread(t *testing.T, sub *InMemorySubscription, n int) {
+	t.Helper()
+	for i := 1; i <= n; i++ {
+		require.NoError(t, sub.Publish(1, &model.Comment{ID: i}))
+	}
+}
+
+func dropped(policy OverflowPolicy) int64 {
+	if counter, ok := droppedEvents.Get(string(policy)).(*expvar.Int); ok {
+		return counter.Value()
+	}
+	return 0
+}
+
+// readIDs id комментариев, пришедших в канал до паузы
+func readIDs(ch SubscriptionChan) []int {
+	var ids []int
+	for {
+		select {
+		case c, ok := <-ch:
+			if !ok {
+				return ids
+			}
+			ids = append(ids, c.ID)
+		case <-time.After(50 * time.Millisecond):
+			return ids
+		}
+	}
+}
+
+func TestOverflow_DropOldest(t *testing.T) {
+	sub := NewInMemorySubscription(Options{BufferSize: 3, Overflow: DropOldest})
+	defer sub.Close()
+	before := dropped(DropOldest)
+	ch := sub.Subscribe(ctx, 1)
+
+	// один комментарий может уже ждать в канале, остальные - в буфере
+	publishUnread(t, sub, 10)
+	ids := readIDs(ch)
+	require.Equal(t, []int{8, 9, 10}, ids[len(ids)-3:], "в буфере остаются самые новые")
+	require.Less(t, len(ids), 10)
+	require.Greater(t, dropped(DropOldest), before)
+}
+
+func TestOverflow_DropNewest(t *testing.T) {
+	sub := NewInMemorySubscription(Options{BufferSize: 3, Overflow: DropNewest})
+	defer sub.Close()
+	ch := sub.Subscribe(ctx, 1)
+
+	publishUnread(t, sub, 10)
+	ids := readIDs(ch)
+	require.Equal(t, []int{1, 2, 3}, ids[:3], "новые комментарии не вытесняют старые")
+	require.Less(t, len(ids), 10)
+}
+
+func TestOverflow_Disconnect(t *testing.T) {
+	sub := NewInMemorySubscription(Options{BufferSize: 3, Overflow: Disconnect})
+	defer sub.Close()
+	disconnectedBefore := disconnectedSubscribers.Value()
+
+	var reported error
+	subCtx := WithErrorHandler(ctx, func(err error) { reported = err })
+	ch := sub.Subscribe(subCtx, 1)
+	other := sub.Subscribe(ctx, 2)
+
+	publishUnread(t, sub, 10)
+	requireClosed(t, ch)
+	require.ErrorIs(t, reported, ErrSubscriberTooSlow)
+	require.Equal(t, disconnectedBefore+1, disconnectedSubscribers.Value())
+
+	// отключается только медленный подписчик
+	require.NoError(t, sub.Publish(2, &model.Comment{ID: 100}))
+	require.Equal(t, 100, (<-other).ID)
+}
+
+func TestOptions_Normalized(t *testing.T) {
+	require.Equal(t, DefaultOptions(), Options{}.normalized())
+	require.Equal(t, Options{BufferSize: 16, Overflow: DropNewest}, Options{BufferSize: -1, Overflow: DropNewest}.normalized())
+	require.Equal(t, Options{BufferSize: 3, Overflow: DropOldest}, Options{BufferSize: 3, Overflow: "unknown"}.normalized())
+
+	// с нулевым буфером подписка работает с буфером по умолчанию, а не падает при переполнении
+	sub := NewInMemorySubscription(Options{BufferSize: 0, Overflow: DropOldest})
+	defer sub.Close()
+	ch := sub.Subscribe(ctx, 1)
+	publishUnread(t, sub, 3)
+	require.Equal(t, []int{1, 2, 3}, readIDs(ch))
+}
+
+func TestSlowSubscriber_NoGoroutinePerEvent(t *testing.T) {
+	sub := NewInMemorySubscription(DefaultOptions())
+	defer sub.Close()
+	sub.Subscribe(ctx, 1)
+	before := runtime.NumGoroutine()
+
+	// подписчик ничего не читает: число горутин не растет с числом комментариев
+	publishUnread(t, sub, 1000)
+	require.LessOrEqual(t, runtime.NumGoroutine(), before)
+}
@@ -1,11 +1,15 @@
 package subscription
 
-import "OzonTestTask/internal/model"
+import (
+	"OzonTestTask/internal/model"
+	"context"
+)
 
 type SubscriptionChan chan *model.Comment
 
 type Subscription interface {
-	Subscribe(postID int) SubscriptionChan
+	// Subscribe Подписка на новые комментарии поста до отмены ctx, после чего канал закрывается
+	Subscribe(ctx context.Context, postID int) SubscriptionChan
 	Publish(postID int, comment *model.Comment) error
 	Close() error
 }
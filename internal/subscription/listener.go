@@ -0,0 +1,235 @@
+package subscription
+
+import (
+	"context"
+	"log"
+	"sync"
+	"time"
+
+	"github.com/jackc/pgx/v5"
+	"github.com/jackc/pgx/v5/pgconn"
+)
+
+// задержка перед переподключением растет вдвое после каждой неудачи, но не больше максимальной
+const (
+	minReconnectDelay = 100 * time.Millisecond
+	maxReconnectDelay = 30 * time.Second
+)
+
+// listenConn Соединение, на котором выполняются LISTEN/UNLISTEN и ожидаются уведомления.
+// Выделено в интерфейс, чтобы listener можно было проверить без базы
+type listenConn interface {
+	Exec(ctx context.Context, sql string) error
+	WaitForNotification(ctx context.Context) (*pgconn.Notification, error)
+	Close(ctx context.Context) error
+}
+
+type pgxListenConn struct {
+	conn *pgx.Conn
+}
+
+func (c pgxListenConn) Exec(ctx context.Context, sql string) error {
+	_, err := c.conn.Exec(ctx, sql)
+	return err
+}
+
+func (c pgxListenConn) WaitForNotification(ctx context.Context) (*pgconn.Notification, error) {
+	return c.conn.WaitForNotification(ctx)
+}
+
+func (c pgxListenConn) Close(ctx context.Context) error {
+	return c.conn.Close(ctx)
+}
+
+// handler Обработчик уведомления. Вызывается из горутины listener, поэтому не должен блокироваться
+type handler func(payload string)
+
+// listener Одно выделенное соединение на процесс, на котором слушаются все каналы.
+// На канал выполняется LISTEN, пока на него есть хотя бы один обработчик, и UNLISTEN после ухода последнего.
+// Уведомление раздается всем обработчикам канала. После потери соединения listener переподключается
+// и повторяет LISTEN для всех каналов с обработчиками
+type listener struct {
+	dial func(ctx context.Context) (listenConn, error)
+
+	mu       sync.Mutex
+	handlers map[string]map[int]handler // канал -> id обработчика -> обработчик
+	nextID   int
+	started  bool
+
+	// changed сигнал о том, что набор каналов изменился и ожидание уведомления нужно прервать
+	changed chan struct{}
+	ctx     context.Context
+	cancel  context.CancelFunc
+	done    chan struct{}
+}
+
+func newListener(dial func(ctx context.Context) (listenConn, error)) *listener {
+	ctx, cancel := context.WithCancel(context.Background())
+	return &listener{
+		dial:     dial,
+		handlers: make(map[string]map[int]handler),
+		changed:  make(chan struct{}, 1),
+		ctx:      ctx,
+		cancel:   cancel,
+		done:     make(chan struct{}),
+	}
+}
+
+// listen Добавление обработчика на канал. Соединение открывается при первом вызове.
+// Возвращает функцию отписки, повторные вызовы которой ничего не делают
+func (l *listener) listen(channel string, h handler) (unlisten func()) {
+	l.mu.Lock()
+	if l.handlers[channel] == nil {
+		l.handlers[channel] = make(map[int]handler)
+	}
+	id := l.nextID
+	l.nextID++
+	l.handlers[channel][id] = h
+	if !l.started {
+		l.started = true
+		go l.run()
+	}
+	l.mu.Unlock()
+	l.notifyChanged()
+
+	var once sync.Once
+	return func() {
+		once.Do(func() {
+			l.mu.Lock()
+			delete(l.handlers[channel], id)
+			if len(l.handlers[channel]) == 0 {
+				delete(l.handlers, channel)
+			}
+			l.mu.Unlock()
+			l.notifyChanged()
+		})
+	}
+}
+
+func (l *listener) notifyChanged() {
+	select {
+	case l.changed <- struct{}{}:
+	default:
+	}
+}
+
+// close Остановка listener и закрытие соединения. После возврата обработчики больше не вызываются
+func (l *listener) close() {
+	l.mu.Lock()
+	started := l.started
+	l.started = true // после close соединение больше не открывается
+	l.mu.Unlock()
+
+	l.cancel()
+	if started {
+		<-l.done
+	}
+}
+
+// run Подключение и переподключение после ошибок до вызова close
+func (l *listener) run() {
+	defer close(l.done)
+	delay := minReconnectDelay
+	for {
+		conn, err := l.dial(l.ctx)
+		if err == nil {
+			delay = minReconnectDelay
+			err = l.serve(conn)
+			_ = conn.Close(context.Background())
+		}
+		if l.ctx.Err() != nil {
+			return
+		}
+		log.Printf("соединение для LISTEN потеряно: %v, переподключение через %s", err, delay)
+		select {
+		case <-l.ctx.Done():
+			return
+		case <-time.After(delay):
+		}
+		delay = min(delay*2, maxReconnectDelay)
+	}
+}
+
+// serve Работа на одном соединении: синхронизация LISTEN с набором каналов и раздача уведомлений.
+// Возвращает ошибку соединения
+func (l *listener) serve(conn listenConn) error {
+	listening := make(map[string]bool) // каналы, на которые выполнен LISTEN на этом соединении
+	for {
+		if err := l.sync(conn, listening); err != nil {
+			return err
+		}
+
+		waitCtx, cancelWait := context.WithCancel(l.ctx)
+		waiterDone := make(chan struct{})
+		go func() {
+			defer close(waiterDone)
+			select {
+			case <-l.changed:
+				cancelWait()
+			case <-waitCtx.Done():
+			}
+		}()
+		notification, err := conn.WaitForNotification(waitCtx)
+		interrupted := waitCtx.Err() != nil
+		cancelWait()
+		// жду выхода горутины до следующего sync: иначе она может забрать сигнал,
+		// отправленный уже во время следующего ожидания, и новый канал останется без LISTEN
+		<-waiterDone
+
+		if err != nil {
+			if l.ctx.Err() != nil {
+				return l.ctx.Err()
+			}
+			// ожидание прервано из-за изменения набора каналов, соединение при этом остается рабочим
+			if interrupted {
+				continue
+			}
+			return err
+		}
+		l.dispatch(notification)
+	}
+}
+
+// sync LISTEN на новые каналы и UNLISTEN на каналы, у которых не осталось обработчиков
+func (l *listener) sync(conn listenConn, listening map[string]bool) error {
+	l.mu.Lock()
+	var toListen, toUnlisten []string
+	for channel := range l.handlers {
+		if !listening[channel] {
+			toListen = append(toListen, channel)
+		}
+	}
+	for channel := range listening {
+		if _, ok := l.handlers[channel]; !ok {
+			toUnlisten = append(toUnlisten, channel)
+		}
+	}
+	l.mu.Unlock()
+
+	for _, channel := range toListen {
+		if err := conn.Exec(l.ctx, "LISTEN "+pgx.Identifier{channel}.Sanitize()); err != nil {
+			return err
+		}
+		listening[channel] = true
+	}
+	for _, channel := range toUnlisten {
+		if err := conn.Exec(l.ctx, "UNLISTEN "+pgx.Identifier{channel}.Sanitize()); err != nil {
+			return err
+		}
+		delete(listening, channel)
+	}
+	return nil
+}
+
+func (l *listener) dispatch(notification *pgconn.Notification) {
+	l.mu.Lock()
+	handlers := make([]handler, 0, len(l.handlers[notification.Channel]))
+	for _, h := range l.handlers[notification.Channel] {
+		handlers = append(handlers, h)
+	}
+	l.mu.Unlock()
+
+	for _, h := range handlers {
+		h(notification.Payload)
+	}
+}
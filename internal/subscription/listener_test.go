@@ -0,0 +1,187 @@
+package subscription
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"slices"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/jackc/pgx/v5/pgconn"
+	"github.com/stretchr/testify/require"
+)
+
+// fakeConn Соединение без базы: запоминает выполненные команды и отдает уведомления из канала
+type fakeConn struct {
+	mu            sync.Mutex
+	commands      []string
+	notifications chan *pgconn.Notification
+	broken        chan struct{}
+}
+
+func newFakeConn() *fakeConn {
+	return &fakeConn{notifications: make(chan *pgconn.Notification), broken: make(chan struct{})}
+}
+
+func (c *fakeConn) Exec(_ context.Context, sql string) error {
+	select {
+	case <-c.broken:
+		return errors.New("соединение разорвано")
+	default:
+	}
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.commands = append(c.commands, sql)
+	return nil
+}
+
+func (c *fakeConn) WaitForNotification(ctx context.Context) (*pgconn.Notification, error) {
+	select {
+	case n := <-c.notifications:
+		return n, nil
+	case <-c.broken:
+		return nil, errors.New("соединение разорвано")
+	case <-ctx.Done():
+		return nil, ctx.Err()
+	}
+}
+
+func (c *fakeConn) Close(context.Context) error {
+	return nil
+}
+
+func (c *fakeConn) executed() []string {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return slices.Clone(c.commands)
+}
+
+func (c *fakeConn) notify(channel, payload string) {
+	c.notifications <- &pgconn.Notification{Channel: channel, Payload: payload}
+}
+
+// newFakeListener listener, который на каждое подключение отдает новое соединение в conns
+func newFakeListener() (*listener, chan *fakeConn) {
+	conns := make(chan *fakeConn, 10)
+	l := newListener(func(context.Context) (listenConn, error) {
+		conn := newFakeConn()
+		conns <- conn
+		return conn, nil
+	})
+	return l, conns
+}
+
+func waitCommands(t *testing.T, conn *fakeConn, expected ...string) {
+	t.Helper()
+	require.Eventually(t, func() bool {
+		return slices.Equal(conn.executed(), expected)
+	}, time.Second, 5*time.Millisecond, "выполнены команды %v", conn.executed())
+}
+
+func TestListener_RefCount(t *testing.T) {
+	l, conns := newFakeListener()
+	defer l.close()
+
+	unlisten1 := l.listen("post_1", func(string) {})
+	unlisten2 := l.listen("post_1", func(string) {})
+	conn := <-conns
+	waitCommands(t, conn, `LISTEN "post_1"`)
+
+	// канал слушается, пока на него подписан хоть кто-то
+	unlisten1()
+	unlisten1()
+	time.Sleep(20 * time.Millisecond)
+	waitCommands(t, conn, `LISTEN "post_1"`)
+
+	unlisten2()
+	waitCommands(t, conn, `LISTEN "post_1"`, `UNLISTEN "post_1"`)
+
+	l.listen("post_1", func(string) {})
+	waitCommands(t, conn, `LISTEN "post_1"`, `UNLISTEN "post_1"`, `LISTEN "post_1"`)
+	require.Empty(t, conns, "соединение для LISTEN должно быть одно")
+}
+
+func TestListener_FanOut(t *testing.T) {
+	l, conns := newFakeListener()
+	defer l.close()
+
+	received := make(chan string, 10)
+	l.listen("post_1", func(payload string) { received <- "первый " + payload })
+	l.listen("post_1", func(payload string) { received <- "второй " + payload })
+	l.listen("post_2", func(payload string) { received <- "чужой " + payload })
+	conn := <-conns
+	require.Eventually(t, func() bool { return len(conn.executed()) == 2 }, time.Second, 5*time.Millisecond)
+
+	conn.notify("post_1", "коммент")
+	got := []string{<-received, <-received}
+	slices.Sort(got)
+	require.Equal(t, []string{"второй коммент", "первый коммент"}, got)
+	require.Empty(t, received)
+}
+
+func TestListener_ListenAfterNotification(t *testing.T) {
+	l, conns := newFakeListener()
+	defer l.close()
+
+	l.listen("post_0", func(string) {})
+	conn := <-conns
+	waitCommands(t, conn, `LISTEN "post_0"`)
+
+	// ожидание, завершенное уведомлением, не должно забрать сигнал о новом канале у следующего ожидания:
+	// иначе LISTEN выполнится только после какого-нибудь постороннего уведомления
+	for i := 1; i <= 100; i++ {
+		conn.notify("post_0", "коммент")
+		channel := fmt.Sprintf("post_%d", i)
+		l.listen(channel, func(string) {})
+		require.Eventually(t, func() bool {
+			return slices.Contains(conn.executed(), fmt.Sprintf("LISTEN %q", channel))
+		}, 500*time.Millisecond, time.Millisecond, "LISTEN на %s не выполнен", channel)
+	}
+}
+
+func TestListener_Reconnect(t *testing.T) {
+	l, conns := newFakeListener()
+	defer l.close()
+
+	received := make(chan string, 1)
+	l.listen("post_1", func(payload string) { received <- payload })
+	first := <-conns
+	waitCommands(t, first, `LISTEN "post_1"`)
+
+	// после разрыва listener подключается заново и повторяет LISTEN
+	close(first.broken)
+	var second *fakeConn
+	select {
+	case second = <-conns:
+	case <-time.After(time.Second):
+		t.Fatal("listener не переподключился")
+	}
+	waitCommands(t, second, `LISTEN "post_1"`)
+
+	second.notify("post_1", "после переподключения")
+	require.Equal(t, "после переподключения", <-received)
+}
+
+func TestListener_Close(t *testing.T) {
+	l, conns := newFakeListener()
+	l.listen("post_1", func(string) {})
+	<-conns
+
+	done := make(chan struct{})
+	go func() {
+		l.close()
+		close(done)
+	}()
+	select {
+	case <-done:
+	case <-time.After(time.Second):
+		t.Fatal("listener не остановился")
+	}
+
+	// после закрытия соединение больше не открывается
+	l.listen("post_2", func(string) {})
+	time.Sleep(20 * time.Millisecond)
+	require.Empty(t, conns)
+}
@@ -2,7 +2,13 @@ package subscription
 
 import (
 	"OzonTestTask/internal/model"
+	"context"
+	"encoding/json"
+	"errors"
 	"github.com/stretchr/testify/require"
+	"runtime"
+	"strings"
+	"sync"
 	"testing"
 	"time"
 )
@@ -12,10 +18,10 @@ func TestDBSubscribeAndPublish(t *testing.T) {
 	pool, err := NewPGXPool(connStr)
 	require.NoError(t, err)
 	defer pool.Close()
-	sub := NewPostgresSubscription(pool)
+	sub := NewPostgresSubscription(pool, nil, DefaultOptions())
 	postID := 1
-	sub1 := sub.Subscribe(postID)
-	sub2 := sub.Subscribe(postID)
+	sub1 := sub.Subscribe(ctx, postID)
+	sub2 := sub.Subscribe(ctx, postID)
 	time.Sleep(1 * time.Second)
 
 	comment := &model.Comment{PostID: postID, Author: "Я", Content: "Тестик"}
@@ -26,3 +32,149 @@ func TestDBSubscribeAndPublish(t *testing.T) {
 	res2 := <-sub2
 	require.Equal(t, comment, res2)
 }
+
+// fakeLoader Хранилище комментариев для уведомлений, в которых пришел только id
+type fakeLoader struct {
+	mu       sync.Mutex
+	comments map[int]*model.Comment
+	calls    int
+	gate     chan struct{} // если задан, загрузка ждет сигнала из него
+}
+
+func (l *fakeLoader) GetCommentSnapshot(_ context.Context, id int) (*model.Comment, error) {
+	if l.gate != nil {
+		<-l.gate
+	}
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	l.calls++
+	comment, ok := l.comments[id]
+	if !ok {
+		return nil, errors.New("комментарий не найден")
+	}
+	return comment, nil
+}
+
+func TestDBPublish_QuotesAndLargePayload(t *testing.T) {
+	connStr := "postgres://postgres:password@db_test:5432/posts-comments-test-db?sslmode=disable"
+	pool, err := NewPGXPool(connStr)
+	require.NoError(t, err)
+	defer pool.Close()
+
+	large := &model.Comment{ID: 7, PostID: 1, Author: "Я", Content: strings.Repeat("😀", 2000)}
+	sub := NewPostgresSubscription(pool, &fakeLoader{comments: map[int]*model.Comment{7: large}}, DefaultOptions())
+	defer sub.Close()
+	ch := sub.Subscribe(ctx, 1)
+	time.Sleep(1 * time.Second)
+
+	// кавычки в тексте не ломают запрос
+	quoted := &model.Comment{ID: 6, PostID: 1, Author: "O'Brien", Content: `'); DROP TABLE comments; --`}
+	require.NoError(t, sub.Publish(1, quoted))
+	require.Equal(t, quoted, <-ch)
+
+	// слишком большой комментарий догружается по id
+	require.NoError(t, sub.Publish(1, large))
+	require.Equal(t, large, <-ch)
+}
+
+func TestNotificationPayload(t *testing.T) {
+	comment := &model.Comment{ID: 5, PostID: 1, Author: "O'Brien", Content: "it's"}
+	payload, err := notificationPayload(comment)
+	require.NoError(t, err)
+	var n notification
+	require.NoError(t, json.Unmarshal([]byte(payload), &n))
+	require.Equal(t, comment, n.Comment)
+
+	// 2000 символов по 4 байта в UTF-8 не помещаются в лимит NOTIFY
+	comment.Content = strings.Repeat("😀", 2000)
+	payload, err = notificationPayload(comment)
+	require.NoError(t, err)
+	require.LessOrEqual(t, len(payload), maxPayloadSize)
+	n = notification{}
+	require.NoError(t, json.Unmarshal([]byte(payload), &n))
+	require.Nil(t, n.Comment)
+	require.Equal(t, 5, n.ID)
+}
+
+func TestPostgresSubscription_HydratesByID(t *testing.T) {
+	l, conns := newFakeListener()
+	loader := &fakeLoader{comments: map[int]*model.Comment{5: {ID: 5, PostID: 1, Content: "Длинный"}}}
+	sub := newPostgresSubscription(l, loader, DefaultOptions())
+	defer sub.Close()
+
+	ch1 := sub.Subscribe(ctx, 1)
+	ch2 := sub.Subscribe(ctx, 1)
+	conn := <-conns
+	waitCommands(t, conn, `LISTEN "post_1"`)
+
+	conn.notify("post_1", `{"id": 5}`)
+	require.Equal(t, "Длинный", (<-ch1).Content)
+	require.Equal(t, "Длинный", (<-ch2).Content)
+	// комментарий загружается один раз на уведомление, а не на каждого подписчика
+	loader.mu.Lock()
+	require.Equal(t, 1, loader.calls)
+	loader.mu.Unlock()
+
+	// комментарий, который не удалось загрузить, пропускается
+	conn.notify("post_1", `{"id": 404}`)
+	conn.notify("post_1", `{"comment": {"id": 6, "content": "Следующий"}}`)
+	require.Equal(t, "Следующий", (<-ch1).Content)
+}
+
+func TestPostgresSubscription_HydrationKeepsOrder(t *testing.T) {
+	l, conns := newFakeListener()
+	loader := &fakeLoader{
+		comments: map[int]*model.Comment{5: {ID: 5, PostID: 1, Content: "Длинный"}},
+		gate:     make(chan struct{}),
+	}
+	sub := newPostgresSubscription(l, loader, DefaultOptions())
+	defer sub.Close()
+
+	ch := sub.Subscribe(ctx, 1)
+	conn := <-conns
+	waitCommands(t, conn, `LISTEN "post_1"`)
+	other := sub.Subscribe(ctx, 2)
+	waitCommands(t, conn, `LISTEN "post_1"`, `LISTEN "post_2"`)
+
+	// пока комментарий 5 догружается, следующий комментарий поста ждет его
+	conn.notify("post_1", `{"id": 5}`)
+	conn.notify("post_1", `{"comment": {"id": 6, "content": "Следующий"}}`)
+	// уведомления других постов не задерживаются
+	conn.notify("post_2", `{"comment": {"id": 7, "content": "Другой пост"}}`)
+	require.Equal(t, 7, (<-other).ID)
+
+	close(loader.gate)
+	require.Equal(t, 5, (<-ch).ID)
+	require.Equal(t, 6, (<-ch).ID)
+}
+
+func TestPostgresUnsubscribeOnContextCancel(t *testing.T) {
+	before := runtime.NumGoroutine()
+	l, conns := newFakeListener()
+	sub := newPostgresSubscription(l, nil, DefaultOptions())
+
+	ctx1, cancel1 := context.WithCancel(ctx)
+	ctx2, cancel2 := context.WithCancel(ctx)
+	ch1 := sub.Subscribe(ctx1, 1)
+	ch2 := sub.Subscribe(ctx2, 1)
+	conn := <-conns
+	waitCommands(t, conn, `LISTEN "post_1"`)
+
+	conn.notify("post_1", `{"comment": {"id": 1, "content": "Коммент"}}`)
+	require.Equal(t, "Коммент", (<-ch1).Content)
+
+	// пока на пост подписан второй клиент, канал продолжает слушаться
+	cancel1()
+	requireClosed(t, ch1)
+	waitCommands(t, conn, `LISTEN "post_1"`)
+
+	cancel2()
+	requireClosed(t, ch2)
+	waitCommands(t, conn, `LISTEN "post_1"`, `UNLISTEN "post_1"`)
+	sub.mu.Lock()
+	require.Empty(t, sub.posts)
+	sub.mu.Unlock()
+
+	require.NoError(t, sub.Close())
+	requireNoLeak(t, before)
+}
@@ -7,72 +7,225 @@ import (
 	"fmt"
 	"github.com/jackc/pgx/v5"
 	"github.com/jackc/pgx/v5/pgxpool"
+	"log"
+	"sync"
 )
 
+// maxPayloadSize PostgreSQL принимает payload NOTIFY короче 8000 байт
+const maxPayloadSize = 7999
+
+// CommentLoader Загрузка комментария, который не поместился в уведомление целиком.
+// Отдается снимок на момент создания, а не текущее состояние: правка до доставки не должна менять событие
+type CommentLoader interface {
+	GetCommentSnapshot(ctx context.Context, commentID int) (*model.Comment, error)
+}
+
+// notification Payload NOTIFY: комментарий целиком или, если он слишком большой, только его id
+type notification struct {
+	Comment *model.Comment `json:"comment,omitempty"`
+	ID      int            `json:"id,omitempty"`
+}
+
+// postSubscribers Подписчики одного поста. На канал поста в listener один обработчик,
+// поэтому уведомление разбирается (и при необходимости догружается из хранилища) один раз для всех
+type postSubscribers struct {
+	subscribers map[*subscriber]struct{}
+	unlisten    func()
+
+	// пока догружается комментарий, следующие уведомления поста ждут в backlog,
+	// чтобы подписчики получили комментарии в порядке публикации
+	backlog  []notification
+	draining bool
+}
+
 type PostgresSubscription struct {
-	pool   *pgxpool.Pool // pgx для работы с механизмом Listen/Notify в PostgreSQL
+	pool     *pgxpool.Pool // pgx для работы с механизмом Listen/Notify в PostgreSQL
+	listener *listener     // одно соединение с LISTEN на все посты
+	comments CommentLoader
+
+	mu     sync.Mutex
+	posts  map[int]*postSubscribers
+	opts   Options
+	active sync.WaitGroup // горутины, отписывающие подписчиков и догружающие комментарии
+
+	ctx    context.Context // отменяется в Close
 	cancel context.CancelFunc
 }
 
-func NewPostgresSubscription(pool *pgxpool.Pool) *PostgresSubscription {
-	return &PostgresSubscription{pool: pool}
+func NewPostgresSubscription(pool *pgxpool.Pool, comments CommentLoader, opts Options) *PostgresSubscription {
+	// соединение для LISTEN не берется из пула, чтобы не занимать его навсегда
+	sub := newPostgresSubscription(newListener(func(ctx context.Context) (listenConn, error) {
+		conn, err := pgx.ConnectConfig(ctx, pool.Config().ConnConfig.Copy())
+		if err != nil {
+			return nil, err
+		}
+		return pgxListenConn{conn: conn}, nil
+	}), comments, opts)
+	sub.pool = pool
+	return sub
 }
 
-// Subscribe Отправка Listen в БД
-func (sub *PostgresSubscription) Subscribe(postID int) SubscriptionChan {
-	ch := make(SubscriptionChan)
+func newPostgresSubscription(l *listener, comments CommentLoader, opts Options) *PostgresSubscription {
 	ctx, cancel := context.WithCancel(context.Background())
-	sub.cancel = cancel
+	return &PostgresSubscription{
+		listener: l,
+		comments: comments,
+		posts:    make(map[int]*postSubscribers),
+		opts:     opts.normalized(),
+		ctx:      ctx,
+		cancel:   cancel,
+	}
+}
+
+func postChannel(postID int) string {
+	return fmt.Sprintf("post_%d", postID)
+}
+
+// Subscribe Подписка на канал поста в общем соединении для LISTEN. После отмены ctx
+// подписчик удаляется (UNLISTEN, если он был последним у поста), а его канал закрывается
+func (sub *PostgresSubscription) Subscribe(ctx context.Context, postID int) SubscriptionChan {
+	s := newSubscriber(ctx, sub.opts)
+
+	sub.mu.Lock()
+	group, ok := sub.posts[postID]
+	if !ok {
+		group = &postSubscribers{subscribers: make(map[*subscriber]struct{})}
+		group.unlisten = sub.listener.listen(postChannel(postID), func(payload string) {
+			sub.receive(postID, payload)
+		})
+		sub.posts[postID] = group
+	}
+	group.subscribers[s] = struct{}{}
+	sub.mu.Unlock()
 
-	// горутина, подписывающая на канал и ожидающая нового комментария из базы
+	sub.active.Add(1)
 	go func() {
-		conn, err := pgx.Connect(ctx, sub.pool.Config().ConnConfig.ConnString())
-		if err != nil {
-			close(ch)
-			return
+		defer sub.active.Done()
+		<-s.ctx.Done()
+		sub.mu.Lock()
+		delete(group.subscribers, s)
+		if len(group.subscribers) == 0 && sub.posts[postID] == group {
+			group.unlisten()
+			delete(sub.posts, postID)
 		}
-		defer conn.Close(ctx)
+		sub.mu.Unlock()
+		s.close()
+	}()
+	return s.ch
+}
 
-		channel := fmt.Sprintf("post_%d", postID)
-		_, err = conn.Exec(ctx, fmt.Sprintf("LISTEN %s;", channel))
-		if err != nil {
-			close(ch)
+// receive Разбор уведомления из канала поста. Комментарий, от которого пришел только id,
+// догружается из хранилища в отдельной горутине поста (drain), чтобы не задерживать уведомления других постов.
+// Уведомления этого поста, пришедшие за ним, встают в очередь и отправляются после него
+func (sub *PostgresSubscription) receive(postID int, payload string) {
+	var n notification
+	if err := json.Unmarshal([]byte(payload), &n); err != nil {
+		log.Printf("некорректное уведомление в канале %s: %v", postChannel(postID), err)
+		return
+	}
+
+	sub.mu.Lock()
+	group, ok := sub.posts[postID]
+	if !ok {
+		sub.mu.Unlock()
+		return
+	}
+	if n.Comment != nil && !group.draining {
+		sub.mu.Unlock()
+		sub.deliver(postID, n.Comment)
+		return
+	}
+	group.backlog = append(group.backlog, n)
+	if !group.draining {
+		group.draining = true
+		sub.active.Add(1)
+		go sub.drain(postID, group)
+	}
+	sub.mu.Unlock()
+}
+
+// drain Отправка очереди уведомлений поста по порядку с догрузкой комментариев, пришедших только с id
+func (sub *PostgresSubscription) drain(postID int, group *postSubscribers) {
+	defer sub.active.Done()
+	for {
+		sub.mu.Lock()
+		if len(group.backlog) == 0 {
+			group.draining = false
+			sub.mu.Unlock()
 			return
 		}
+		n := group.backlog[0]
+		group.backlog = group.backlog[1:]
+		sub.mu.Unlock()
 
-		// ожидание Notify из базы
-		for {
-			notification, err := conn.WaitForNotification(ctx)
-			if err != nil {
-				close(ch)
-				return
+		comment := n.Comment
+		if comment == nil {
+			var err error
+			if comment, err = sub.comments.GetCommentSnapshot(sub.ctx, n.ID); err != nil {
+				log.Printf("не удалось загрузить комментарий %d из уведомления: %v", n.ID, err)
+				continue
 			}
-			var comment model.Comment
-			err = json.Unmarshal([]byte(notification.Payload), &comment)
-			if err != nil {
-				return
-			}
-			ch <- &comment
 		}
-	}()
-	return ch
+		sub.deliver(postID, comment)
+	}
+}
+
+// deliver Отправка комментария всем подписчикам поста
+func (sub *PostgresSubscription) deliver(postID int, comment *model.Comment) {
+	sub.mu.Lock()
+	var subscribers []*subscriber
+	if group, ok := sub.posts[postID]; ok {
+		subscribers = make([]*subscriber, 0, len(group.subscribers))
+		for s := range group.subscribers {
+			subscribers = append(subscribers, s)
+		}
+	}
+	sub.mu.Unlock()
+
+	for _, s := range subscribers {
+		s.send(comment)
+	}
+}
+
+// notificationPayload Комментарий целиком, а если он не помещается в лимит NOTIFY - только его id
+func notificationPayload(comment *model.Comment) (string, error) {
+	payload, err := json.Marshal(notification{Comment: comment})
+	if err != nil {
+		return "", err
+	}
+	if len(payload) > maxPayloadSize {
+		payload, err = json.Marshal(notification{ID: comment.ID})
+		if err != nil {
+			return "", err
+		}
+	}
+	return string(payload), nil
 }
 
-// Publish Отправка Notify в БД
+// Publish Отправка Notify в БД. Канал и payload передаются параметрами pg_notify,
+// поэтому кавычки в тексте комментария не ломают запрос
 func (sub *PostgresSubscription) Publish(postID int, comment *model.Comment) error {
-	commentJSON, err := json.Marshal(comment)
+	payload, err := notificationPayload(comment)
 	if err != nil {
 		return fmt.Errorf("не удалось сериализовать комментарий в JSON: %v", err)
 	}
 
-	_, err = sub.pool.Exec(context.Background(),
-		fmt.Sprintf("NOTIFY post_%d, '%s';", postID, string(commentJSON)))
+	_, err = sub.pool.Exec(context.Background(), "SELECT pg_notify($1, $2)", postChannel(postID), payload)
 	return err
 }
 
+// Close Остановка listener, отписка всех подписчиков и закрытие их каналов
 func (sub *PostgresSubscription) Close() error {
-	if sub.cancel != nil {
-		sub.cancel()
+	sub.listener.close()
+	sub.cancel()
+
+	sub.mu.Lock()
+	for _, group := range sub.posts {
+		for s := range group.subscribers {
+			s.cancel()
+		}
 	}
+	sub.mu.Unlock()
+	sub.active.Wait()
 	return nil
 }
@@ -0,0 +1,176 @@
+package subscription
+
+import (
+	"OzonTestTask/internal/model"
+	"context"
+	"errors"
+	"expvar"
+	"fmt"
+	"sync"
+)
+
+// ErrSubscriberTooSlow Подписка закрыта сервером, потому что клиент не успевал читать события
+var ErrSubscriberTooSlow = errors.New("подписчик не успевает получать события, подписка закрыта")
+
+// OverflowPolicy Что делать с новым комментарием, если буфер подписчика заполнен
+type OverflowPolicy string
+
+const (
+	DropOldest OverflowPolicy = "drop_oldest" // вытеснить самый старый непрочитанный комментарий
+	DropNewest OverflowPolicy = "drop_newest" // отбросить новый комментарий
+	Disconnect OverflowPolicy = "disconnect"  // закрыть подписку с ошибкой ErrSubscriberTooSlow
+)
+
+// ParseOverflowPolicy Политика по значению из конфигурации
+func ParseOverflowPolicy(value string) (OverflowPolicy, error) {
+	switch policy := OverflowPolicy(value); policy {
+	case DropOldest, DropNewest, Disconnect:
+		return policy, nil
+	}
+	return "", fmt.Errorf("неизвестная политика переполнения буфера подписчика: %s", value)
+}
+
+// Options Настройки буфера подписчиков
+type Options struct {
+	BufferSize int // сколько непрочитанных комментариев хранится на подписчика
+	Overflow   OverflowPolicy
+}
+
+func DefaultOptions() Options {
+	return Options{BufferSize: 16, Overflow: DropOldest}
+}
+
+// normalized Настройки, в которых некорректные значения (буфер меньше 1, неизвестная политика)
+// заменены значениями по умолчанию: с пустым буфером drop_oldest вытеснял бы из пустой очереди
+func (o Options) normalized() Options {
+	defaults := DefaultOptions()
+	if o.BufferSize < 1 {
+		o.BufferSize = defaults.BufferSize
+	}
+	if _, err := ParseOverflowPolicy(string(o.Overflow)); err != nil {
+		o.Overflow = defaults.Overflow
+	}
+	return o
+}
+
+// Метрики публикуются через expvar и доступны на /debug/vars:
+// отброшенные комментарии по политикам и подписчики, отключенные за медленное чтение
+var (
+	droppedEvents           = expvar.NewMap("subscription_dropped_events")
+	disconnectedSubscribers = expvar.NewInt("subscription_disconnected_subscribers")
+)
+
+type errorHandlerKey struct{}
+
+// WithErrorHandler Обработчик ошибки, с которой сервер закрывает подписку (ErrSubscriberTooSlow).
+// Вызывается до закрытия канала подписки
+func WithErrorHandler(ctx context.Context, handle func(err error)) context.Context {
+	return context.WithValue(ctx, errorHandlerKey{}, handle)
+}
+
+// subscriber Канал одного подписчика с ограниченным буфером. Подписка живет до отмены ctx
+// (например, до разрыва websocket), после чего реализация Subscription удаляет подписчика и вызывает close
+type subscriber struct {
+	ch     SubscriptionChan
+	ctx    context.Context
+	cancel context.CancelFunc
+	opts   Options
+
+	mu     sync.Mutex
+	queue  []*model.Comment // комментарии, еще не переданные в канал
+	closed bool
+	ready  chan struct{} // сигнал горутине forward, что в буфере появился комментарий
+	done   chan struct{} // закрывается после закрытия канала подписчика
+}
+
+func newSubscriber(ctx context.Context, opts Options) *subscriber {
+	s := &subscriber{
+		ch:    make(SubscriptionChan),
+		opts:  opts,
+		ready: make(chan struct{}, 1),
+		done:  make(chan struct{}),
+	}
+	s.ctx, s.cancel = context.WithCancel(ctx)
+	go s.forward()
+	return s
+}
+
+// send Добавление комментария в буфер, не блокирующее публикующего.
+// Если подписка закрыта, комментарий отбрасывается, если буфер заполнен - действует политика переполнения
+func (s *subscriber) send(comment *model.Comment) {
+	s.mu.Lock()
+	if s.closed {
+		s.mu.Unlock()
+		return
+	}
+	if len(s.queue) >= s.opts.BufferSize {
+		droppedEvents.Add(string(s.opts.Overflow), 1)
+		switch s.opts.Overflow {
+		case DropNewest:
+			s.mu.Unlock()
+			return
+		case Disconnect:
+			s.closed = true
+			s.mu.Unlock()
+			s.disconnect(ErrSubscriberTooSlow)
+			return
+		default:
+			s.queue[0] = nil
+			s.queue = s.queue[1:]
+		}
+	}
+	s.queue = append(s.queue, comment)
+	s.mu.Unlock()
+
+	select {
+	case s.ready <- struct{}{}:
+	default:
+	}
+}
+
+// disconnect Закрытие подписки сервером: клиент получает ошибку, затем канал закрывается
+func (s *subscriber) disconnect(err error) {
+	disconnectedSubscribers.Add(1)
+	if handle, ok := s.ctx.Value(errorHandlerKey{}).(func(error)); ok {
+		handle(err)
+	}
+	s.cancel()
+}
+
+// forward Единственная горутина подписчика: переносит комментарии из буфера в канал по одному,
+// поэтому медленный клиент не задерживает публикацию и не порождает новых горутин
+func (s *subscriber) forward() {
+	defer close(s.done)
+	defer close(s.ch)
+	for {
+		s.mu.Lock()
+		if len(s.queue) == 0 {
+			s.mu.Unlock()
+			select {
+			case <-s.ready:
+				continue
+			case <-s.ctx.Done():
+				return
+			}
+		}
+		comment := s.queue[0]
+		s.queue[0] = nil
+		s.queue = s.queue[1:]
+		s.mu.Unlock()
+
+		select {
+		case s.ch <- comment:
+		case <-s.ctx.Done():
+			return
+		}
+	}
+}
+
+// close Ожидание закрытия канала. Вызывается после отмены ctx
+func (s *subscriber) close() {
+	s.mu.Lock()
+	s.closed = true
+	s.queue = nil
+	s.mu.Unlock()
+	<-s.done
+}